@@ -19,4 +19,54 @@ type Config struct {
 		Password string
 		Select   int
 	}
+	Smtp map[string]struct {
+		Host            string
+		Port            int
+		Username        string
+		Password        string
+		From            string
+		From_Name       string
+		Tls_Mode        string
+		Tls_Skip_Verify bool
+		Tls_Ca_File     string
+		Tls_Min_Version string
+		Tls_Cert_File   string
+		Tls_Key_File    string
+		Failover        []string
+
+		Auth                 string
+		Oauth2_Access_Token  string
+		Oauth2_Token_Url     string
+		Oauth2_Client_Id     string
+		Oauth2_Client_Secret string
+		Oauth2_Refresh_Token string
+		Oauth2_Scope         string
+	}
+	Email struct {
+		Connect_Timeout   int
+		Command_Timeout   int
+		Data_Timeout      int
+		Pool_Size         int
+		Pool_Idle_Timeout int
+		Failover_Cooldown int
+
+		Queue_Workers       int
+		Queue_Max_Attempts  int
+		Queue_Retry_Base    int
+		Queue_Retry_Max     int
+		Queue_Poll_Interval int
+
+		Idempotency_Window int
+		Batch_Concurrency  int
+	}
+	Dkim struct {
+		Headers []string
+		Domains []struct {
+			Domain           string
+			Selector         string
+			Private_Key      string
+			Private_Key_File string
+			Headers          []string
+		}
+	}
 }
@@ -1,30 +1,60 @@
 package common
 
 import (
+	"fmt"
 	"gin_base/app/helper/email_helper"
 	"gin_base/app/helper/exception_helper"
 	"gin_base/app/helper/request_helper"
 	"gin_base/app/helper/response_helper"
+	"gin_base/app/logic"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 func Test(c *gin.Context) {
 	response_helper.Success(c, "访问成功")
 }
 
+// emailParam 发送邮件参数，批量发送时每封邮件的参数相同
+type emailParam struct {
+	AuthCode string      `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+	To       interface{} `json:"to" mapstructure:"to" validate:"required" label:"收件人"`
+	Cc       interface{} `json:"cc" mapstructure:"cc" validate:"omitempty" label:"抄送"`
+	Bcc      interface{} `json:"bcc" mapstructure:"bcc" validate:"omitempty" label:"密送"`
+	ReplyTo  interface{} `json:"reply_to" mapstructure:"reply_to" validate:"omitempty" label:"回复地址"`
+	Priority interface{} `json:"priority" mapstructure:"priority" validate:"omitempty" label:"优先级"`
+	Headers  interface{} `json:"headers" mapstructure:"headers" validate:"omitempty" label:"自定义信头"`
+	Subject  string      `json:"subject" mapstructure:"subject" validate:"required" label:"邮件主题"`
+	Body     string      `json:"body" mapstructure:"body" validate:"required" label:"邮件正文"`
+	TextBody string      `json:"text_body" mapstructure:"text_body" validate:"omitempty" label:"纯文本正文"`
+	IsHTML   interface{} `json:"is_html" mapstructure:"is_html" validate:"omitempty" label:"是否HTML格式"`
+	FromName string      `json:"from_name" mapstructure:"from_name" validate:"omitempty" label:"发件人名称"`
+	Account  string      `json:"account" mapstructure:"account" validate:"omitempty" label:"SMTP账号"`
+	Charset  string      `json:"charset" mapstructure:"charset" validate:"omitempty" label:"字符集"`
+	// 会话标识，同一标识的邮件自动回复上一封，也可以通过 in_reply_to 指定回复的 Message-ID
+	ThreadKey string `json:"thread_key" mapstructure:"thread_key" validate:"omitempty" label:"会话标识"`
+	InReplyTo string `json:"in_reply_to" mapstructure:"in_reply_to" validate:"omitempty" label:"回复的Message-ID"`
+	// 邮件分类和退订，启用退订时邮件带有 List-Unsubscribe 信头，退订后收件人加入该分类的抑制列表
+	Category    string      `json:"category" mapstructure:"category" validate:"omitempty" label:"邮件分类"`
+	Unsubscribe interface{} `json:"unsubscribe" mapstructure:"unsubscribe" validate:"omitempty" label:"是否启用退订"`
+	// 收件人在抑制列表中时默认跳过，为 true 时拒绝发送
+	RejectSuppressed interface{} `json:"reject_suppressed" mapstructure:"reject_suppressed" validate:"omitempty" label:"抑制时拒绝发送"`
+	// 异步发送：写入发送队列后立即返回，由工作协程投递，临时错误时自动重试
+	Async interface{} `json:"async" mapstructure:"async" validate:"omitempty" label:"是否异步发送"`
+	// 预约发送时间，RFC 3339 格式（如 2030-01-02T09:00:00+08:00）或 Unix 时间戳，设置后总是异步发送
+	SendAt interface{} `json:"send_at" mapstructure:"send_at" validate:"omitempty" label:"预约发送时间"`
+	// 日历邀请 {method, uid, sequence, summary, description, location, start, end, timezone, organizer, attendees}
+	Calendar interface{} `json:"calendar" mapstructure:"calendar" validate:"omitempty" label:"日历邀请"`
+	// 附件内容较大，不写入请求日志
+	Attachments interface{} `json:"-" mapstructure:"attachments" validate:"omitempty" label:"附件"`
+	Inlines     interface{} `json:"-" mapstructure:"inlines" validate:"omitempty" label:"内联资源"`
+}
+
 func Email(c *gin.Context) {
-	type Param struct {
-		AuthCode string      `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
-		To       string      `json:"to" mapstructure:"to" validate:"required" label:"收件人"`
-		Cc       string      `json:"cc" mapstructure:"cc" validate:"omitempty" label:"抄送"`
-		Subject  string      `json:"subject" mapstructure:"subject" validate:"required" label:"邮件主题"`
-		Body     string      `json:"body" mapstructure:"body" validate:"required" label:"邮件正文"`
-		IsHTML   interface{} `json:"is_html" mapstructure:"is_html" validate:"omitempty" label:"是否HTML格式"`
-		FromName string      `json:"from_name" mapstructure:"from_name" validate:"omitempty" label:"发件人名称"`
-	}
-	var param Param
+	var param emailParam
 	request_helper.InputStruct(c, &param)
 
 	// 验证授权码
@@ -35,52 +65,197 @@ func Email(c *gin.Context) {
 	// 获取请求IP
 	requestIP := c.ClientIP()
 
-	// 获取SMTP配置
-	config := email_helper.GetDefaultConfig()
+	// 校验参数并构建邮件
+	request := parseEmailRequest(c, param)
+	configs, message, skipped := request.Configs, request.Message, request.Skipped
+
+	// 异步发送和预约发送，返回队列ID和 Message-ID，投递状态通过 /api/getEmailStatus 查询
+	if request.Async {
+		outbox, err := email_helper.EnqueueEmail(requestIP, configs[0].Account, message, email_helper.EnqueueOptions{
+			FromName:    param.FromName,
+			RequestData: param,
+			SendAt:      request.SendAt,
+		})
+		if err != nil {
+			exception_helper.CommonException(err.Error())
+		}
+		data := map[string]interface{}{
+			"id":         outbox.Id,
+			"message_id": outbox.MessageId,
+			"status":     outbox.Status,
+		}
+		if len(skipped) > 0 {
+			data["recipients"] = skipped
+		}
+		if outbox.SendAt != nil {
+			data["send_at"] = outbox.SendAt
+			response_helper.Success(c, "邮件已预约发送", data)
+			return
+		}
+		response_helper.Success(c, "邮件已加入发送队列", data)
+		return
+	}
+
+	// 发送邮件，失败时按顺序尝试故障转移账号，客户端断开连接时取消发送
+	result := email_helper.SendEmailWithFailover(c.Request.Context(), configs, message)
+	result.Recipients = append(result.Recipients, skipped...)
+
+	// 记录日志
+	email_helper.LogEmailRequest(requestIP, message, configs[0], result, param)
+
+	// 返回结果，包含每个收件人的投递结果，内容无法用目标字符集表示时附带警告
+	data := map[string]interface{}{
+		"recipients": result.Recipients,
+		"message_id": result.MessageID,
+	}
+	if len(result.Warnings) > 0 {
+		data["warnings"] = result.Warnings
+	}
+	if !result.Success {
+		exception_helper.CommonException(result.Error, http.StatusBadRequest, data)
+	}
+	successMessage := "邮件发送成功"
+	if rejected := email_helper.CountRecipients(result.Recipients, email_helper.RecipientStatusRejected); rejected > 0 {
+		successMessage = fmt.Sprintf("邮件发送成功，%d 个收件人被拒绝", rejected)
+	}
+	if len(skipped) > 0 {
+		successMessage += fmt.Sprintf("，%d 个收件人在抑制列表中未投递", len(skipped))
+	}
+	response_helper.Success(c, successMessage, data)
+}
+
+// emailRequest 校验后的发送请求
+type emailRequest struct {
+	Configs []email_helper.EmailConfig     // SMTP配置（含故障转移账号）
+	Message email_helper.EmailMessage      // 邮件内容
+	Skipped []email_helper.RecipientResult // 在抑制列表中被跳过的收件人
+	Async   bool                           // 是否写入发送队列（异步发送或预约发送）
+	SendAt  time.Time                      // 预约发送时间，零值时立即发送
+}
+
+// parseEmailRequest 校验发送邮件参数并构建邮件，参数错误时抛出异常
+func parseEmailRequest(c *gin.Context, param emailParam) emailRequest {
+	// 获取SMTP配置（含故障转移账号），未指定账号时使用默认账号
+	configs, err := email_helper.GetFailoverChain(param.Account)
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
 
 	// 如果请求参数中传入了 from_name，则覆盖默认值
 	if param.FromName != "" {
-		config.FromName = param.FromName
+		for i := range configs {
+			configs[i].FromName = param.FromName
+		}
 	}
 
-	// 构建邮件消息（逗号分隔转数组）
-	var toList, ccList []string
-	if param.To != "" {
-		toList = strings.Split(param.To, ",")
+	// 解析收件人（支持逗号分隔、字符串数组和 {name, email} 对象数组），并去除重复地址
+	toList, ccList, bccList := email_helper.DedupeRecipients(
+		logic.ParseAddresses(param.To, "收件人"),
+		logic.ParseAddresses(param.Cc, "抄送"),
+		logic.ParseAddresses(param.Bcc, "密送"),
+	)
+	if len(toList) == 0 {
+		exception_helper.CommonException("收件人不能为空")
 	}
-	if param.Cc != "" {
-		ccList = strings.Split(param.Cc, ",")
+	replyToList := logic.ParseAddresses(param.ReplyTo, "回复地址")
+
+	// 邮件分类，启用退订但未指定分类时使用 default
+	category := strings.TrimSpace(param.Category)
+	unsubscribe := logic.ParseBool(param.Unsubscribe)
+	if unsubscribe && category == "" {
+		category = email_helper.DefaultCategory
 	}
-	// 解析 is_html 参数（兼容字符串、数字、布尔）
-	var isHTML bool
-	switch v := param.IsHTML.(type) {
-	case bool:
-		isHTML = v
-	case string:
-		isHTML = v == "1" || v == "true"
-	case float64:
-		isHTML = v == 1
-	case int:
-		isHTML = v == 1
+	if err := email_helper.ValidateCategory(category); err != nil {
+		exception_helper.CommonException(err.Error())
 	}
+	// 退订链接只能对应一个收件人
+	if unsubscribe && len(toList)+len(ccList)+len(bccList) > 1 {
+		exception_helper.CommonException("启用退订时只能有一个收件人（不能抄送、密送），多个收件人请分别发送")
+	}
+	// 跳过抑制列表中的收件人（退信、投诉、退订等），reject_suppressed 为 true 时直接拒绝发送
+	toList, ccList, bccList, skipped := email_helper.FilterSuppressed(category, toList, ccList, bccList)
+	if len(skipped) > 0 && logic.ParseBool(param.RejectSuppressed) {
+		exception_helper.CommonException("收件人在抑制列表中", http.StatusBadRequest, map[string]interface{}{
+			"recipients": skipped,
+		})
+	}
+	if len(toList) == 0 {
+		exception_helper.CommonException("收件人均在抑制列表中", http.StatusBadRequest, map[string]interface{}{
+			"recipients": skipped,
+		})
+	}
+
+	// 校验目标字符集（如 GB18030、Big5、ISO-2022-JP），为空时使用 UTF-8
+	if _, err := email_helper.LookupCharset(param.Charset); err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+	if err := email_helper.ValidateThreadKey(param.ThreadKey); err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+	if err := email_helper.ValidateMessageID(param.InReplyTo); err != nil {
+		exception_helper.CommonException("in_reply_to " + err.Error())
+	}
+	// 解析 is_html 参数（兼容字符串、数字、布尔）
+	isHTML := logic.ParseBool(param.IsHTML)
 
 	message := email_helper.EmailMessage{
-		To:      toList,
-		Cc:      ccList,
-		Subject: param.Subject,
-		Body:    param.Body,
-		IsHTML:  isHTML,
+		To:          toList,
+		Cc:          ccList,
+		Bcc:         bccList,
+		ReplyTo:     replyToList,
+		Priority:    logic.ParsePriority(param.Priority),
+		Headers:     logic.ParseHeaders(param.Headers),
+		Subject:     param.Subject,
+		Body:        param.Body,
+		TextBody:    param.TextBody,
+		IsHTML:      isHTML,
+		Attachments: logic.ParseAttachments(c, param.Attachments),
+		Inlines:     logic.ParseInlines(c, param.Inlines),
+		Charset:     param.Charset,
+		ThreadKey:   param.ThreadKey,
+		Calendar:    logic.ParseCalendar(param.Calendar),
+		Category:    category,
+	}
+	if unsubscribe {
+		unsubscribeURL, err := email_helper.NewUnsubscribeURL(toList[0].Address, category)
+		if err != nil {
+			exception_helper.CommonException(err.Error())
+		}
+		message.UnsubscribeURL = unsubscribeURL
 	}
+	// 回复同一会话的上一封邮件，使收件人客户端归为同一会话
+	message.InReplyTo, message.References = email_helper.ResolveThread(param.ThreadKey, param.InReplyTo)
 
-	// 发送邮件
-	result := email_helper.SendEmail(config, message)
+	// HTML 中的 data: URI 图片自动转为内联资源
+	if message.IsHTML {
+		var embedded []email_helper.Attachment
+		message.Body, embedded = email_helper.EmbedDataURIImages(message.Body)
+		message.Inlines = append(message.Inlines, embedded...)
+	}
+	logic.CheckAttachmentSize(message.Attachments, message.Inlines)
 
-	// 记录日志
-	email_helper.LogEmailRequest(requestIP, message, config, result, param)
+	sendAt := logic.ParseSendAt(param.SendAt)
+	return emailRequest{
+		Configs: configs,
+		Message: message,
+		Skipped: skipped,
+		Async:   logic.ParseBool(param.Async) || !sendAt.IsZero(),
+		SendAt:  sendAt,
+	}
+}
 
-	// 返回结果
-	if !result.Success {
-		exception_helper.CommonException(result.Error)
+// GetEmailPoolStats SMTP 连接池统计
+func GetEmailPoolStats(c *gin.Context) {
+	type Param struct {
+		AuthCode string `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
 	}
-	response_helper.Success(c, "邮件发送成功")
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	response_helper.Success(c, "获取成功", email_helper.GetPoolStats())
 }
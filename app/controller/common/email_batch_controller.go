@@ -0,0 +1,160 @@
+package common
+
+import (
+	"fmt"
+	"gin_base/app/helper/email_helper"
+	"gin_base/app/helper/exception_helper"
+	"gin_base/app/helper/log_helper"
+	"gin_base/app/helper/request_helper"
+	"gin_base/app/helper/response_helper"
+	"github.com/gin-gonic/gin"
+	"os"
+)
+
+// EmailBatch 批量发送邮件，messages 中每一项的参数与 /api/email 相同（不需要 auth_code）
+// 先校验每一项，参数错误的项不发送，其余的项并发发送；单项失败不影响其他项，返回每一项的结果
+func EmailBatch(c *gin.Context) {
+	type Param struct {
+		AuthCode string      `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Messages interface{} `json:"messages" mapstructure:"messages" validate:"required" label:"邮件列表"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+	items, ok := param.Messages.([]interface{})
+	if !ok || len(items) == 0 {
+		exception_helper.CommonException("邮件列表必须为非空数组")
+	}
+	if len(items) > email_helper.MaxBatchSize {
+		exception_helper.CommonException(fmt.Sprintf("单次最多发送 %d 封邮件", email_helper.MaxBatchSize))
+	}
+	// 上传的文件无法对应到某一封邮件，附件只支持 base64 方式
+	if len(request_helper.ParamMultipartFile(c, "attachments")) > 0 || len(request_helper.ParamMultipartFile(c, "inlines")) > 0 {
+		exception_helper.CommonException("批量发送不支持上传文件，附件请使用 base64 方式")
+	}
+
+	results := make([]map[string]interface{}, len(items))
+	params := make([]emailParam, len(items))
+	requests := make([]*emailRequest, len(items))
+
+	// 校验每一项，参数错误的项记录错误信息
+	for i, item := range items {
+		results[i] = map[string]interface{}{"index": i, "success": false}
+		data, ok := item.(map[string]interface{})
+		if !ok {
+			results[i]["error"] = "邮件参数必须为对象"
+			continue
+		}
+		data["auth_code"] = param.AuthCode
+		params[i], requests[i] = parseBatchItem(c, data, results[i])
+	}
+
+	successCount := sendBatch(c, params, requests, results, "")
+	response_helper.Success(c, fmt.Sprintf("批量发送完成，成功 %d 封，失败 %d 封", successCount, len(items)-successCount), map[string]interface{}{
+		"success_count": successCount,
+		"failed_count":  len(items) - successCount,
+		"results":       results,
+	})
+}
+
+// parseBatchItem 校验批量中的一封邮件，参数错误时错误信息写入 result 并返回 nil
+func parseBatchItem(c *gin.Context, data map[string]interface{}, result map[string]interface{}) (emailParam, *emailRequest) {
+	var param emailParam
+	var request *emailRequest
+	exception := exception_helper.CatchException(func() {
+		request_helper.MapStruct(data, &param)
+		parsed := parseEmailRequest(c, param)
+		request = &parsed
+	})
+	if exception != nil {
+		result["error"] = exception.Message
+		// 默认的 Data 为空数组，只返回携带了数据的异常（如被抑制的收件人）
+		if _, empty := exception.Data.([]int); !empty && exception.Data != nil {
+			result["data"] = exception.Data
+		}
+	}
+	return param, request
+}
+
+// sendBatch 并发发送校验通过的邮件，每封邮件的结果写入 results，返回成功的数量
+// batchId 不为空时写入每封邮件的发送记录（个性化发送）
+func sendBatch(c *gin.Context, params []emailParam, requests []*emailRequest, results []map[string]interface{}, batchId string) int {
+	requestIP := c.ClientIP()
+	email_helper.RunBatch(len(requests), email_helper.BatchConcurrency(), func(i int) {
+		if requests[i] == nil {
+			return
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				log_helper.Error(fmt.Sprintf("批量发送邮件panic: %v", r))
+				results[i]["error"] = fmt.Sprintf("发送异常: %v", r)
+			}
+		}()
+		sendBatchItem(c, requestIP, params[i], *requests[i], batchId, results[i])
+	})
+
+	successCount := 0
+	for _, result := range results {
+		if result["success"] == true {
+			successCount++
+		}
+	}
+	return successCount
+}
+
+// sendBatchItem 发送批量中的一封邮件并记录发送记录，结果写入 result
+func sendBatchItem(c *gin.Context, requestIP string, param emailParam, request emailRequest, batchId string, result map[string]interface{}) {
+	if len(request.Skipped) > 0 {
+		result["recipients"] = request.Skipped
+	}
+
+	// 异步发送和预约发送写入发送队列
+	if request.Async {
+		outbox, err := email_helper.EnqueueEmail(requestIP, request.Configs[0].Account, request.Message, email_helper.EnqueueOptions{
+			FromName:    param.FromName,
+			RequestData: param,
+			SendAt:      request.SendAt,
+			BatchId:     batchId,
+		})
+		if err != nil {
+			result["error"] = err.Error()
+			return
+		}
+		result["success"] = true
+		result["outbox_id"] = outbox.Id
+		result["message_id"] = outbox.MessageId
+		result["status"] = outbox.Status
+		if outbox.SendAt != nil {
+			result["send_at"] = outbox.SendAt
+		}
+		return
+	}
+
+	sendResult := email_helper.SendEmailWithFailover(c.Request.Context(), request.Configs, request.Message)
+	sendResult.Recipients = append(sendResult.Recipients, request.Skipped...)
+
+	// 同步记录发送记录，返回记录ID
+	emailLog := email_helper.NewEmailLog(requestIP, request.Message, request.Configs[0], sendResult, param)
+	emailLog.BatchId = batchId
+	if err := email_helper.SaveEmailLog(&emailLog, sendResult); err == nil {
+		result["email_log_id"] = emailLog.Id
+	}
+	if sendResult.Success {
+		email_helper.SaveThreadMessage(request.Message, sendResult.MessageID, 0)
+	}
+
+	result["success"] = sendResult.Success
+	result["message_id"] = sendResult.MessageID
+	result["recipients"] = sendResult.Recipients
+	if len(sendResult.Warnings) > 0 {
+		result["warnings"] = sendResult.Warnings
+	}
+	if !sendResult.Success {
+		result["error"] = sendResult.Error
+		result["error_type"] = sendResult.ErrorType
+	}
+}
@@ -1,7 +1,9 @@
 package common
 
 import (
+	"fmt"
 	"gin_base/app/helper/db_helper"
+	"gin_base/app/helper/email_helper"
 	"gin_base/app/helper/exception_helper"
 	"gin_base/app/helper/request_helper"
 	"gin_base/app/helper/response_helper"
@@ -10,9 +12,14 @@ import (
 	"gorm.io/gorm"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// 按筛选条件重发时单次最多重发的记录数
+const maxResendCount = 500
+
 // EmailLogIndex 邮件记录首页
 func EmailLogIndex(c *gin.Context) {
 	c.HTML(http.StatusOK, "email_log.html", nil)
@@ -26,6 +33,7 @@ func GetEmailLogList(c *gin.Context) {
 		StartDate string `json:"start_date" mapstructure:"start_date" validate:"omitempty" label:"开始日期"`
 		EndDate   string `json:"end_date" mapstructure:"end_date" validate:"omitempty" label:"结束日期"`
 		Success   string `json:"success" mapstructure:"success" validate:"omitempty" label:"发送状态"`
+		Account   string `json:"account" mapstructure:"account" validate:"omitempty" label:"SMTP账号"`
 	}
 	var param Param
 	request_helper.InputStruct(c, &param)
@@ -35,26 +43,9 @@ func GetEmailLogList(c *gin.Context) {
 		exception_helper.CommonException("授权码错误")
 	}
 
-	// 基础查询条件（日期+关键词）
+	// 基础查询条件（日期+关键词+账号），与删除、重发的筛选条件相同
 	baseQuery := func(db *gorm.DB) *gorm.DB {
-		// 开始日期筛选
-		if param.StartDate != "" {
-			startTime, _ := time.ParseInLocation("2006-01-02", param.StartDate, time.Local)
-			db = db.Where("created_at >= ?", startTime)
-		}
-		// 结束日期筛选
-		if param.EndDate != "" {
-			endTime, _ := time.ParseInLocation("2006-01-02", param.EndDate, time.Local)
-			endTime = endTime.Add(24*time.Hour - time.Second) // 结束日期当天 23:59:59
-			db = db.Where("created_at <= ?", endTime)
-		}
-		// 关键词模糊查询
-		if param.Keyword != "" {
-			keyword := "%" + param.Keyword + "%"
-			db = db.Where("to_email LIKE ? OR subject LIKE ? OR body LIKE ? OR request_ip LIKE ?",
-				keyword, keyword, keyword, keyword)
-		}
-		return db
+		return filterEmailLog(db, param.Keyword, param.StartDate, param.EndDate, "", param.Account)
 	}
 
 	// 统计成功数量
@@ -65,20 +56,57 @@ func GetEmailLogList(c *gin.Context) {
 	var failedCount int64
 	baseQuery(db_helper.Db().Model(&model.EmailLog{})).Where("success = 0").Count(&failedCount)
 
-	// 构建列表查询
-	db := baseQuery(db_helper.Db().Model(&model.EmailLog{})).Order("id DESC")
-
-	// 发送状态筛选
-	if param.Success == "1" {
-		db = db.Where("success = 1")
-	} else if param.Success == "0" {
-		db = db.Where("success = 0")
-	}
+	// 构建列表查询（含发送状态筛选）
+	db := filterEmailLog(db_helper.Db().Model(&model.EmailLog{}), param.Keyword, param.StartDate, param.EndDate, param.Success, param.Account).Order("id DESC")
 
 	// 分页查询
 	result := db_helper.AutoPage(c, db)
+
+	// 附加投递尝试记录和收件人投递结果
+	var ids []interface{}
+	for _, item := range result["list"].([]map[string]interface{}) {
+		ids = append(ids, item["id"])
+	}
+	attemptMap := make(map[uint][]model.EmailLogAttempt)
+	recipientMap := make(map[uint][]model.EmailLogRecipient)
+	if len(ids) > 0 {
+		var attempts []model.EmailLogAttempt
+		db_helper.Db().Where("email_log_id IN ?", ids).Order("email_log_id, sort").Find(&attempts)
+		for _, attempt := range attempts {
+			attemptMap[attempt.EmailLogId] = append(attemptMap[attempt.EmailLogId], attempt)
+		}
+		var recipients []model.EmailLogRecipient
+		db_helper.Db().Where("email_log_id IN ?", ids).Order("email_log_id, id").Find(&recipients)
+		for _, recipient := range recipients {
+			recipientMap[recipient.EmailLogId] = append(recipientMap[recipient.EmailLogId], recipient)
+		}
+	}
+	for _, item := range result["list"].([]map[string]interface{}) {
+		id, _ := strconv.ParseUint(fmt.Sprintf("%v", item["id"]), 10, 64)
+		item["attempts"] = attemptMap[uint(id)]
+		item["recipients"] = recipientMap[uint(id)]
+	}
+
 	result["success_count"] = successCount
 	result["failed_count"] = failedCount
+
+	// 可筛选的账号：配置中的账号和历史记录中出现过的账号
+	accounts := email_helper.GetAccountNames()
+	var logAccounts []string
+	db_helper.Db().Model(&model.EmailLog{}).Distinct().Order("account").Pluck("account", &logAccounts)
+	for _, account := range logAccounts {
+		exists := false
+		for _, item := range accounts {
+			if item == account {
+				exists = true
+				break
+			}
+		}
+		if !exists && account != "" {
+			accounts = append(accounts, account)
+		}
+	}
+	result["accounts"] = accounts
 	response_helper.Success(c, "查询成功", result)
 }
 
@@ -90,6 +118,7 @@ func DeleteEmailLog(c *gin.Context) {
 		StartDate string `json:"start_date" mapstructure:"start_date" validate:"omitempty" label:"开始日期"`
 		EndDate   string `json:"end_date" mapstructure:"end_date" validate:"omitempty" label:"结束日期"`
 		Success   string `json:"success" mapstructure:"success" validate:"omitempty" label:"发送状态"`
+		Account   string `json:"account" mapstructure:"account" validate:"omitempty" label:"SMTP账号"`
 	}
 	var param Param
 	request_helper.InputStruct(c, &param)
@@ -100,39 +129,144 @@ func DeleteEmailLog(c *gin.Context) {
 	}
 
 	// 构建删除条件
-	db := db_helper.Db().Model(&model.EmailLog{})
+	db := filterEmailLog(db_helper.Db().Model(&model.EmailLog{}), param.Keyword, param.StartDate, param.EndDate, param.Success, param.Account)
+
+	// 先删除投递尝试记录和收件人投递结果，再删除发送记录
+	for _, child := range []interface{}{&model.EmailLogAttempt{}, &model.EmailLogRecipient{}} {
+		if err := db_helper.Db().Where("email_log_id IN (?)", db.Session(&gorm.Session{}).Select("id")).Delete(child).Error; err != nil {
+			exception_helper.CommonException("删除失败: " + err.Error())
+		}
+	}
+
+	// 执行删除
+	result := db.Delete(&model.EmailLog{})
+	if result.Error != nil {
+		exception_helper.CommonException("删除失败: " + result.Error.Error())
+	}
+
+	response_helper.Success(c, "删除成功", map[string]interface{}{
+		"deleted_count": result.RowsAffected,
+	})
+}
+
+// ResendEmailLog 重发邮件，传 id 时立即重发该记录并返回发送结果，否则将筛选结果（与删除相同的筛选条件，默认只包含失败的记录）写入发送队列
+// 传 resend_account 时使用该账号发送，否则使用原记录的账号；新的发送记录的 resend_of 为原始记录ID
+func ResendEmailLog(c *gin.Context) {
+	type Param struct {
+		AuthCode  string `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Id        uint   `json:"id" mapstructure:"id" validate:"omitempty" label:"记录ID"`
+		Keyword   string `json:"keyword" mapstructure:"keyword" validate:"omitempty" label:"关键词"`
+		StartDate string `json:"start_date" mapstructure:"start_date" validate:"omitempty" label:"开始日期"`
+		EndDate   string `json:"end_date" mapstructure:"end_date" validate:"omitempty" label:"结束日期"`
+		Success   string `json:"success" mapstructure:"success" validate:"omitempty" label:"发送状态"`
+		Account   string `json:"account" mapstructure:"account" validate:"omitempty" label:"SMTP账号"`
+		// 重发使用的账号，为空时使用原记录的账号
+		ResendAccount string `json:"resend_account" mapstructure:"resend_account" validate:"omitempty" label:"重发使用的SMTP账号"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+	if param.ResendAccount != "" {
+		if _, err := email_helper.GetAccountConfig(param.ResendAccount); err != nil {
+			exception_helper.CommonException(err.Error())
+		}
+	}
+	requestIP := c.ClientIP()
+
+	// 重发单条记录
+	if param.Id > 0 {
+		var emailLog model.EmailLog
+		if err := db_helper.Db().Where("id = ?", param.Id).First(&emailLog).Error; err != nil {
+			exception_helper.CommonException("发送记录不存在")
+		}
+		newLog, result, err := email_helper.ResendEmailLog(c.Request.Context(), emailLog, param.ResendAccount, requestIP)
+		if err != nil {
+			exception_helper.CommonException(err.Error())
+		}
+		data := map[string]interface{}{
+			"email_log_id": newLog.Id,
+			"resend_of":    newLog.ResendOf,
+			"recipients":   result.Recipients,
+			"message_id":   result.MessageID,
+		}
+		if !result.Success {
+			exception_helper.CommonException(result.Error, http.StatusBadRequest, data)
+		}
+		response_helper.Success(c, "重发成功", data)
+		return
+	}
+
+	// 重发筛选结果，未指定发送状态时只重发失败的记录，避免重复发送已成功的邮件
+	if param.Success == "" {
+		param.Success = "0"
+	}
+	db := filterEmailLog(db_helper.Db().Model(&model.EmailLog{}), param.Keyword, param.StartDate, param.EndDate, param.Success, param.Account)
+	var total int64
+	db.Session(&gorm.Session{}).Count(&total)
+	if total == 0 {
+		exception_helper.CommonException("没有可重发的记录")
+	}
+	if total > maxResendCount {
+		exception_helper.CommonException(fmt.Sprintf("单次最多重发 %d 条，请缩小筛选范围", maxResendCount))
+	}
+	var emailLogs []model.EmailLog
+	db.Order("id").Find(&emailLogs)
+
+	var queued []map[string]interface{}
+	errs := []map[string]interface{}{}
+	for _, emailLog := range emailLogs {
+		outbox, err := email_helper.EnqueueResend(emailLog, param.ResendAccount, requestIP)
+		if err != nil {
+			errs = append(errs, map[string]interface{}{"email_log_id": emailLog.Id, "error": err.Error()})
+			continue
+		}
+		queued = append(queued, map[string]interface{}{"email_log_id": emailLog.Id, "outbox_id": outbox.Id})
+	}
+
+	message := fmt.Sprintf("%d 封邮件已加入发送队列", len(queued))
+	if len(errs) > 0 {
+		message += fmt.Sprintf("，%d 封无法重发", len(errs))
+	}
+	response_helper.Success(c, message, map[string]interface{}{
+		"queued_count": len(queued),
+		"queued":       queued,
+		"errors":       errs,
+	})
+}
+
+// filterEmailLog 按关键词、日期、发送状态和账号筛选发送记录
+func filterEmailLog(db *gorm.DB, keyword string, startDate string, endDate string, success string, account string) *gorm.DB {
+	// SMTP账号筛选
+	if account != "" {
+		db = db.Where("account = ?", account)
+	}
 
 	// 开始日期筛选
-	if param.StartDate != "" {
-		startTime, _ := time.ParseInLocation("2006-01-02", param.StartDate, time.Local)
+	if startDate != "" {
+		startTime, _ := time.ParseInLocation("2006-01-02", startDate, time.Local)
 		db = db.Where("created_at >= ?", startTime)
 	}
 	// 结束日期筛选
-	if param.EndDate != "" {
-		endTime, _ := time.ParseInLocation("2006-01-02", param.EndDate, time.Local)
+	if endDate != "" {
+		endTime, _ := time.ParseInLocation("2006-01-02", endDate, time.Local)
 		endTime = endTime.Add(24*time.Hour - time.Second)
 		db = db.Where("created_at <= ?", endTime)
 	}
 	// 关键词模糊查询
-	if param.Keyword != "" {
-		keyword := "%" + param.Keyword + "%"
-		db = db.Where("to_email LIKE ? OR subject LIKE ? OR body LIKE ? OR request_ip LIKE ?",
-			keyword, keyword, keyword, keyword)
+	if keyword != "" {
+		like := "%" + keyword + "%"
+		db = db.Where("to_email LIKE ? OR subject LIKE ? OR body LIKE ? OR request_ip LIKE ? OR thread_key = ? OR message_id = ? OR batch_id = ?",
+			like, like, like, like, keyword, strings.Trim(keyword, "<>"), keyword)
 	}
 	// 发送状态筛选
-	if param.Success == "1" {
+	if success == "1" {
 		db = db.Where("success = 1")
-	} else if param.Success == "0" {
+	} else if success == "0" {
 		db = db.Where("success = 0")
 	}
-
-	// 执行删除
-	result := db.Delete(&model.EmailLog{})
-	if result.Error != nil {
-		exception_helper.CommonException("删除失败: " + result.Error.Error())
-	}
-
-	response_helper.Success(c, "删除成功", map[string]interface{}{
-		"deleted_count": result.RowsAffected,
-	})
+	return db
 }
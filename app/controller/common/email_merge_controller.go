@@ -0,0 +1,98 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin_base/app/helper/email_helper"
+	"gin_base/app/helper/exception_helper"
+	"gin_base/app/helper/request_helper"
+	"gin_base/app/helper/response_helper"
+	"gin_base/app/logic"
+	"github.com/gin-gonic/gin"
+	"os"
+)
+
+// EmailMerge 个性化发送（邮件合并），使用每个收件人的变量分别渲染主题和正文模板后发送
+// 除 to、cc、bcc 外的参数与 /api/email 相同，所有收件人共用；每封邮件单独写入发送记录，通过 batch_id 关联
+func EmailMerge(c *gin.Context) {
+	type Param struct {
+		AuthCode   string      `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Subject    string      `json:"subject" mapstructure:"subject" validate:"required" label:"主题模板"`
+		Body       string      `json:"body" mapstructure:"body" validate:"required" label:"正文模板"`
+		TextBody   string      `json:"text_body" mapstructure:"text_body" validate:"omitempty" label:"纯文本正文模板"`
+		IsHTML     interface{} `json:"is_html" mapstructure:"is_html" validate:"omitempty" label:"是否HTML格式"`
+		Recipients interface{} `json:"recipients" mapstructure:"recipients" validate:"required" label:"收件人列表"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	// 收件人列表 [{to, cc, bcc, variables}]，表单提交时为 JSON 字符串
+	recipients, ok := param.Recipients.([]interface{})
+	if value, isString := param.Recipients.(string); isString {
+		ok = json.Unmarshal([]byte(value), &recipients) == nil
+	}
+	if !ok || len(recipients) == 0 {
+		exception_helper.CommonException("收件人列表必须为非空数组")
+	}
+	if len(recipients) > email_helper.MaxBatchSize {
+		exception_helper.CommonException(fmt.Sprintf("单次最多发送 %d 封邮件", email_helper.MaxBatchSize))
+	}
+
+	// 模板错误时所有邮件都无法发送，直接返回错误
+	tmpl, err := email_helper.ParseMergeTemplate(param.Subject, param.Body, param.TextBody, logic.ParseBool(param.IsHTML))
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+
+	// 所有收件人共用的参数
+	shared := request_helper.Input(c)
+	for _, field := range []string{"recipients", "to", "cc", "bcc", "idempotency_key"} {
+		delete(shared, field)
+	}
+
+	batchId := email_helper.NewBatchId()
+	results := make([]map[string]interface{}, len(recipients))
+	params := make([]emailParam, len(recipients))
+	requests := make([]*emailRequest, len(recipients))
+
+	// 渲染并校验每一封邮件，变量缺失或参数错误的邮件记录错误信息
+	for i, item := range recipients {
+		results[i] = map[string]interface{}{"index": i, "success": false}
+		recipient, ok := item.(map[string]interface{})
+		if !ok {
+			results[i]["error"] = "收件人必须为对象"
+			continue
+		}
+		variables, ok := recipient["variables"].(map[string]interface{})
+		if !ok && recipient["variables"] != nil {
+			results[i]["error"] = "variables 必须为对象"
+			continue
+		}
+		rendered, err := tmpl.Render(variables)
+		if err != nil {
+			results[i]["error"] = err.Error()
+			continue
+		}
+
+		data := make(map[string]interface{}, len(shared)+5)
+		for key, value := range shared {
+			data[key] = value
+		}
+		data["to"], data["cc"], data["bcc"] = recipient["to"], recipient["cc"], recipient["bcc"]
+		data["subject"], data["body"], data["text_body"] = rendered.Subject, rendered.Body, rendered.TextBody
+		params[i], requests[i] = parseBatchItem(c, data, results[i])
+	}
+
+	successCount := sendBatch(c, params, requests, results, batchId)
+	response_helper.Success(c, fmt.Sprintf("个性化发送完成，成功 %d 封，失败 %d 封", successCount, len(recipients)-successCount), map[string]interface{}{
+		"batch_id":      batchId,
+		"success_count": successCount,
+		"failed_count":  len(recipients) - successCount,
+		"results":       results,
+	})
+}
@@ -0,0 +1,166 @@
+package common
+
+import (
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/helper/email_helper"
+	"gin_base/app/helper/exception_helper"
+	"gin_base/app/helper/request_helper"
+	"gin_base/app/helper/response_helper"
+	"gin_base/app/logic"
+	"gin_base/app/model"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"os"
+)
+
+// GetEmailStatus 查询异步发送的投递状态
+func GetEmailStatus(c *gin.Context) {
+	type Param struct {
+		AuthCode  string `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Id        uint   `json:"id" mapstructure:"id" validate:"omitempty" label:"队列ID"`
+		MessageId string `json:"message_id" mapstructure:"message_id" validate:"omitempty" label:"Message-ID"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	outbox, err := email_helper.GetOutbox(param.Id, param.MessageId)
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+
+	// 每次投递的发送记录，以及最近一次投递的收件人结果
+	var logs []model.EmailLog
+	db_helper.Db().Select("id, success, error, error_type, account, smtp_host, created_at").
+		Where("outbox_id = ?", outbox.Id).Order("id").Find(&logs)
+	deliveries := make([]map[string]interface{}, 0, len(logs))
+	for _, emailLog := range logs {
+		deliveries = append(deliveries, map[string]interface{}{
+			"email_log_id": emailLog.Id,
+			"success":      emailLog.Success == 1,
+			"error":        emailLog.Error,
+			"error_type":   emailLog.ErrorType,
+			"account":      emailLog.Account,
+			"smtp_host":    emailLog.SmtpHost,
+			"created_at":   emailLog.CreatedAt,
+		})
+	}
+	recipients := []model.EmailLogRecipient{}
+	if outbox.EmailLogId > 0 {
+		db_helper.Db().Where("email_log_id = ?", outbox.EmailLogId).Order("id").Find(&recipients)
+	}
+
+	response_helper.Success(c, "查询成功", map[string]interface{}{
+		"id":              outbox.Id,
+		"message_id":      outbox.MessageId,
+		"status":          outbox.Status,
+		"attempts":        outbox.Attempts,
+		"max_attempts":    outbox.MaxAttempts,
+		"next_attempt_at": outbox.NextAttemptAt,
+		"last_error":      outbox.LastError,
+		"error_type":      outbox.ErrorType,
+		"send_at":         outbox.SendAt,
+		"sent_at":         outbox.SentAt,
+		"canceled_at":     outbox.CanceledAt,
+		"created_at":      outbox.CreatedAt,
+		"deliveries":      deliveries,
+		"recipients":      recipients,
+	})
+}
+
+// GetScheduledEmailList 预约发送列表API
+func GetScheduledEmailList(c *gin.Context) {
+	type Param struct {
+		AuthCode string `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Keyword  string `json:"keyword" mapstructure:"keyword" validate:"omitempty" label:"关键词"`
+		Status   string `json:"status" mapstructure:"status" validate:"omitempty,oneof=scheduled pending sending sent failed canceled" label:"状态"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	db := db_helper.Db().Model(&model.EmailOutbox{}).Where("send_at IS NOT NULL")
+	if param.Keyword != "" {
+		keyword := "%" + param.Keyword + "%"
+		db = db.Where("to_email LIKE ? OR subject LIKE ?", keyword, keyword)
+	}
+
+	// 统计等待发送的数量
+	var scheduledCount int64
+	db.Session(&gorm.Session{}).Where("status = ?", email_helper.OutboxStatusScheduled).Count(&scheduledCount)
+
+	if param.Status != "" {
+		db = db.Where("status = ?", param.Status)
+	}
+	db = db.Omit("message", "request_data").Order("send_at DESC, id DESC")
+
+	// 分页查询
+	result := db_helper.AutoPage(c, db)
+	result["scheduled_count"] = scheduledCount
+	response_helper.Success(c, "查询成功", result)
+}
+
+// RescheduleEmail 修改预约发送时间
+func RescheduleEmail(c *gin.Context) {
+	type Param struct {
+		AuthCode string      `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Id       uint        `json:"id" mapstructure:"id" validate:"required" label:"队列ID"`
+		SendAt   interface{} `json:"send_at" mapstructure:"send_at" validate:"required" label:"预约发送时间"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	sendAt := logic.ParseSendAt(param.SendAt)
+	if sendAt.IsZero() {
+		exception_helper.CommonException("预约发送时间不能为空")
+	}
+	outbox, err := email_helper.RescheduleOutbox(param.Id, sendAt)
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+
+	response_helper.Success(c, "修改成功", map[string]interface{}{
+		"id":      outbox.Id,
+		"status":  outbox.Status,
+		"send_at": outbox.SendAt,
+	})
+}
+
+// CancelEmail 取消预约或等待投递的邮件
+func CancelEmail(c *gin.Context) {
+	type Param struct {
+		AuthCode string `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Id       uint   `json:"id" mapstructure:"id" validate:"required" label:"队列ID"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	outbox, err := email_helper.CancelOutbox(param.Id)
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+
+	response_helper.Success(c, "取消成功", map[string]interface{}{
+		"id":          outbox.Id,
+		"status":      outbox.Status,
+		"canceled_at": outbox.CanceledAt,
+	})
+}
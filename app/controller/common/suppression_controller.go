@@ -0,0 +1,237 @@
+package common
+
+import (
+	"fmt"
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/helper/email_helper"
+	"gin_base/app/helper/exception_helper"
+	"gin_base/app/helper/request_helper"
+	"gin_base/app/helper/response_helper"
+	"gin_base/app/logic"
+	"gin_base/app/model"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"os"
+	"strings"
+	"time"
+)
+
+// GetSuppressionList 抑制列表API
+func GetSuppressionList(c *gin.Context) {
+	type Param struct {
+		AuthCode string `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Keyword  string `json:"keyword" mapstructure:"keyword" validate:"omitempty" label:"关键词"`
+		Reason   string `json:"reason" mapstructure:"reason" validate:"omitempty" label:"原因"`
+		Scope    string `json:"scope" mapstructure:"scope" validate:"omitempty,oneof=global category" label:"作用范围"`
+		Category string `json:"category" mapstructure:"category" validate:"omitempty" label:"邮件分类"`
+		Status   string `json:"status" mapstructure:"status" validate:"omitempty,oneof=active expired" label:"状态"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	now := time.Now()
+	// 基础查询条件（关键词+原因+作用范围）
+	baseQuery := func(db *gorm.DB) *gorm.DB {
+		if param.Keyword != "" {
+			keyword := "%" + param.Keyword + "%"
+			db = db.Where("email LIKE ? OR note LIKE ?", keyword, keyword)
+		}
+		if param.Reason != "" {
+			db = db.Where("reason = ?", param.Reason)
+		}
+		if param.Scope == "global" {
+			db = db.Where("category = ''")
+		} else if param.Scope == "category" {
+			db = db.Where("category <> ''")
+		}
+		if param.Category != "" {
+			db = db.Where("category = ?", param.Category)
+		}
+		return db
+	}
+
+	// 统计生效和已过期数量
+	var activeCount int64
+	baseQuery(db_helper.Db().Model(&model.EmailSuppression{})).
+		Where("expires_at IS NULL OR expires_at > ?", now).Count(&activeCount)
+	var expiredCount int64
+	baseQuery(db_helper.Db().Model(&model.EmailSuppression{})).
+		Where("expires_at <= ?", now).Count(&expiredCount)
+
+	// 构建列表查询
+	db := baseQuery(db_helper.Db().Model(&model.EmailSuppression{})).Order("id DESC")
+
+	// 状态筛选
+	if param.Status == "active" {
+		db = db.Where("expires_at IS NULL OR expires_at > ?", now)
+	} else if param.Status == "expired" {
+		db = db.Where("expires_at <= ?", now)
+	}
+
+	// 分页查询
+	result := db_helper.AutoPage(c, db)
+	result["active_count"] = activeCount
+	result["expired_count"] = expiredCount
+
+	// 可筛选的分类
+	var categories []string
+	db_helper.Db().Model(&model.EmailSuppression{}).Where("category <> ''").Distinct().Order("category").Pluck("category", &categories)
+	result["categories"] = categories
+	response_helper.Success(c, "查询成功", result)
+}
+
+// AddSuppression 添加抑制记录，同一邮箱和作用范围已存在时更新
+func AddSuppression(c *gin.Context) {
+	type Param struct {
+		AuthCode string `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		// 邮箱地址，支持逗号分隔的字符串和字符串数组
+		Email     interface{} `json:"email" mapstructure:"email" validate:"required" label:"邮箱地址"`
+		Reason    string      `json:"reason" mapstructure:"reason" validate:"omitempty" label:"原因"`
+		Category  string      `json:"category" mapstructure:"category" validate:"omitempty" label:"邮件分类"`
+		ExpiresAt string      `json:"expires_at" mapstructure:"expires_at" validate:"omitempty" label:"过期时间"`
+		Source    string      `json:"source" mapstructure:"source" validate:"omitempty" label:"来源"`
+		Note      string      `json:"note" mapstructure:"note" validate:"omitempty" label:"备注"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	expiresAt, err := email_helper.ParseSuppressionExpiry(param.ExpiresAt)
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+	if param.Source == "" {
+		param.Source = email_helper.SuppressionSourceAPI
+	}
+
+	var suppressions []model.EmailSuppression
+	for _, address := range logic.ParseAddresses(param.Email, "邮箱地址") {
+		suppression, err := email_helper.NewSuppression(address.Address, param.Category, param.Reason, param.Source, param.Note, expiresAt)
+		if err != nil {
+			exception_helper.CommonException(err.Error())
+		}
+		suppressions = append(suppressions, suppression)
+	}
+	if len(suppressions) == 0 {
+		exception_helper.CommonException("邮箱地址不能为空")
+	}
+	if err := email_helper.SaveSuppressions(suppressions); err != nil {
+		exception_helper.CommonException("添加失败: " + err.Error())
+	}
+
+	response_helper.Success(c, "添加成功", map[string]interface{}{
+		"saved_count": len(suppressions),
+	})
+}
+
+// ImportSuppression 批量导入抑制记录
+// multipart 上传 file 或提交 content 文本，CSV 格式每行为 email,reason,category,expires_at,note
+func ImportSuppression(c *gin.Context) {
+	type Param struct {
+		AuthCode string `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Content  string `json:"content" mapstructure:"content" validate:"omitempty" label:"导入内容"`
+		// 行中未填写时使用的默认值
+		Reason    string `json:"reason" mapstructure:"reason" validate:"omitempty" label:"原因"`
+		Category  string `json:"category" mapstructure:"category" validate:"omitempty" label:"邮件分类"`
+		ExpiresAt string `json:"expires_at" mapstructure:"expires_at" validate:"omitempty" label:"过期时间"`
+		Source    string `json:"source" mapstructure:"source" validate:"omitempty" label:"来源"`
+		Note      string `json:"note" mapstructure:"note" validate:"omitempty" label:"备注"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	data := logic.ReadUploadFileContent(c, "file")
+	if data == nil {
+		data = []byte(param.Content)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		exception_helper.CommonException("导入内容不能为空")
+	}
+
+	expiresAt, err := email_helper.ParseSuppressionExpiry(param.ExpiresAt)
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+	if param.Source == "" {
+		param.Source = email_helper.SuppressionSourceImport
+	}
+	suppressions, errs := email_helper.ParseSuppressionCSV(data, model.EmailSuppression{
+		Reason:    param.Reason,
+		Category:  param.Category,
+		Source:    param.Source,
+		Note:      param.Note,
+		ExpiresAt: expiresAt,
+	})
+	if err := email_helper.SaveSuppressions(suppressions); err != nil {
+		exception_helper.CommonException("导入失败: " + err.Error())
+	}
+
+	if errs == nil {
+		errs = []string{}
+	}
+	message := fmt.Sprintf("成功导入 %d 条", len(suppressions))
+	if len(errs) > 0 {
+		message += fmt.Sprintf("，%d 行有误", len(errs))
+	}
+	response_helper.Success(c, message, map[string]interface{}{
+		"imported_count": len(suppressions),
+		"errors":         errs,
+	})
+}
+
+// DeleteSuppression 删除抑制记录，按 ID 删除，或按邮箱地址删除（指定 category 时只删除该作用范围，空字符串为全局）
+func DeleteSuppression(c *gin.Context) {
+	type Param struct {
+		AuthCode string      `json:"auth_code" mapstructure:"auth_code" validate:"required" label:"授权码"`
+		Ids      interface{} `json:"ids" mapstructure:"ids" validate:"omitempty" label:"ID"`
+		Email    interface{} `json:"email" mapstructure:"email" validate:"omitempty" label:"邮箱地址"`
+		Category interface{} `json:"category" mapstructure:"category" validate:"omitempty" label:"邮件分类"`
+	}
+	var param Param
+	request_helper.InputStruct(c, &param)
+
+	// 验证授权码
+	if param.AuthCode != os.Getenv("EMAIL_AUTH_CODE") {
+		exception_helper.CommonException("授权码错误")
+	}
+
+	db := db_helper.Db()
+	if ids := logic.ParseIds(param.Ids, "ID"); len(ids) > 0 {
+		db = db.Where("id IN ?", ids)
+	} else {
+		var emails []string
+		for _, address := range logic.ParseAddresses(param.Email, "邮箱地址") {
+			emails = append(emails, strings.ToLower(address.Address))
+		}
+		if len(emails) == 0 {
+			exception_helper.CommonException("ID和邮箱地址不能同时为空")
+		}
+		db = db.Where("email IN ?", emails)
+		if param.Category != nil {
+			db = db.Where("category = ?", strings.TrimSpace(fmt.Sprintf("%v", param.Category)))
+		}
+	}
+
+	result := db.Delete(&model.EmailSuppression{})
+	if result.Error != nil {
+		exception_helper.CommonException("删除失败: " + result.Error.Error())
+	}
+
+	response_helper.Success(c, "删除成功", map[string]interface{}{
+		"deleted_count": result.RowsAffected,
+	})
+}
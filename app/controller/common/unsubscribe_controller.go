@@ -0,0 +1,61 @@
+package common
+
+import (
+	"fmt"
+	"gin_base/app/helper/email_helper"
+	"gin_base/app/helper/log_helper"
+	"gin_base/app/helper/response_helper"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// UnsubscribeIndex 退订确认页面，GET 请求只展示页面，避免邮件安全扫描访问链接时误退订
+func UnsubscribeIndex(c *gin.Context) {
+	token := c.Query("token")
+	email, category, err := email_helper.ParseUnsubscribeToken(token)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "unsubscribe.html", gin.H{"Error": "退订链接无效或已损坏，请直接使用邮件中的退订链接"})
+		return
+	}
+	c.HTML(http.StatusOK, "unsubscribe.html", gin.H{
+		"Email":        email,
+		"Category":     category,
+		"Token":        token,
+		"Unsubscribed": email_helper.IsUnsubscribed(email, category),
+	})
+}
+
+// Unsubscribe 退订：邮箱客户端的 RFC 8058 一键退订（List-Unsubscribe=One-Click）和确认页面提交
+func Unsubscribe(c *gin.Context) {
+	oneClick := c.PostForm("List-Unsubscribe") == "One-Click"
+	method := email_helper.UnsubscribeMethodPage
+	if oneClick {
+		method = email_helper.UnsubscribeMethodOneClick
+	}
+
+	email, category, err := email_helper.ParseUnsubscribeToken(c.Query("token"))
+	if err != nil {
+		if oneClick {
+			response_helper.Common(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		c.HTML(http.StatusBadRequest, "unsubscribe.html", gin.H{"Error": "退订链接无效或已损坏，请直接使用邮件中的退订链接"})
+		return
+	}
+
+	if err := email_helper.RecordUnsubscribe(email, category, method, c.ClientIP(), c.Request.UserAgent()); err != nil {
+		log_helper.Error(fmt.Sprintf("记录退订失败: %v", err))
+		if oneClick {
+			response_helper.Common(c, http.StatusInternalServerError, "退订失败，请稍后重试")
+			return
+		}
+		c.HTML(http.StatusInternalServerError, "unsubscribe.html", gin.H{"Error": "退订失败，请稍后重试"})
+		return
+	}
+
+	if oneClick {
+		response_helper.Success(c, "退订成功")
+		return
+	}
+	c.HTML(http.StatusOK, "unsubscribe.html", gin.H{"Email": email, "Category": category, "Unsubscribed": true, "Done": true})
+}
@@ -1,6 +1,7 @@
 package cron_helper
 
 import (
+	"gin_base/app/helper/email_helper"
 	"gin_base/app/middleware"
 	"github.com/gogits/cron"
 )
@@ -10,6 +11,15 @@ func InitCron() {
 	c.AddFunc("定时清理ip限制缓存", "0 */1 * * * ?", func() {
 		middleware.ClearIpRateLimit()
 	})
+	c.AddFunc("定时清理SMTP空闲连接", "*/30 * * * * ?", func() {
+		email_helper.EvictIdleConnections()
+	})
+	c.AddFunc("定时投递到期的预约邮件", "*/10 * * * * ?", func() {
+		email_helper.ReleaseScheduledEmails()
+	})
+	c.AddFunc("定时清理过期的幂等键", "0 0 */1 * * ?", func() {
+		email_helper.ClearExpiredIdempotencyKeys()
+	})
 
 	c.Start()
 }
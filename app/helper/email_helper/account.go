@@ -0,0 +1,88 @@
+package email_helper
+
+import (
+	"fmt"
+	"gin_base/app/helper/helper"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultAccount 默认 SMTP 账号名称
+const DefaultAccount = "default"
+
+// GetAccountConfig 获取命名 SMTP 账号的配置，account 为空时使用默认账号
+// 默认账号优先使用 SMTP_* 环境变量，未配置 SMTP_HOST 时使用 smtp.yaml 中的 default 账号
+func GetAccountConfig(account string) (EmailConfig, error) {
+	account = strings.ToLower(strings.TrimSpace(account))
+	if account == "" {
+		account = DefaultAccount
+	}
+
+	if account == DefaultAccount {
+		config := GetDefaultConfig()
+		if _, ok := helper.GetAppConfig().Smtp[DefaultAccount]; config.Host != "" || !ok {
+			return config, nil
+		}
+	}
+
+	// viper 读取的 map 键名均为小写
+	profile, ok := helper.GetAppConfig().Smtp[account]
+	if !ok {
+		return EmailConfig{}, fmt.Errorf("SMTP 账号不存在: %s", account)
+	}
+	port := profile.Port
+	if port == 0 {
+		port = 587
+	}
+	config := EmailConfig{
+		Account:       account,
+		Host:          strings.TrimSpace(profile.Host),
+		Port:          port,
+		Username:      strings.TrimSpace(profile.Username),
+		Password:      strings.TrimSpace(profile.Password),
+		From:          strings.TrimSpace(profile.From),
+		FromName:      strings.TrimSpace(profile.From_Name),
+		TLSMode:       strings.TrimSpace(profile.Tls_Mode),
+		TLSSkipVerify: profile.Tls_Skip_Verify,
+		TLSCAFile:     strings.TrimSpace(profile.Tls_Ca_File),
+		TLSMinVersion: strings.TrimSpace(profile.Tls_Min_Version),
+		TLSCertFile:   strings.TrimSpace(profile.Tls_Cert_File),
+		TLSKeyFile:    strings.TrimSpace(profile.Tls_Key_File),
+		Failover:      profile.Failover,
+
+		AuthMechanism:      strings.TrimSpace(profile.Auth),
+		OAuth2AccessToken:  strings.TrimSpace(profile.Oauth2_Access_Token),
+		OAuth2TokenURL:     strings.TrimSpace(profile.Oauth2_Token_Url),
+		OAuth2ClientID:     strings.TrimSpace(profile.Oauth2_Client_Id),
+		OAuth2ClientSecret: strings.TrimSpace(profile.Oauth2_Client_Secret),
+		OAuth2RefreshToken: strings.TrimSpace(profile.Oauth2_Refresh_Token),
+		OAuth2Scope:        strings.TrimSpace(profile.Oauth2_Scope),
+	}
+	applyEmailSettings(&config)
+	return config, nil
+}
+
+// GetAccountNames 获取所有可用的 SMTP 账号名称，默认账号排在第一位
+func GetAccountNames() []string {
+	names := []string{DefaultAccount}
+	var others []string
+	for name := range helper.GetAppConfig().Smtp {
+		if name != DefaultAccount {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+// applyEmailSettings 写入 email.yaml 中的超时、连接池和故障转移配置，所有账号共用
+func applyEmailSettings(config *EmailConfig) {
+	emailConfig := helper.GetAppConfig().Email
+	config.ConnectTimeout = time.Duration(emailConfig.Connect_Timeout) * time.Second
+	config.CommandTimeout = time.Duration(emailConfig.Command_Timeout) * time.Second
+	config.DataTimeout = time.Duration(emailConfig.Data_Timeout) * time.Second
+	config.PoolSize = emailConfig.Pool_Size
+	config.PoolIdleTimeout = time.Duration(emailConfig.Pool_Idle_Timeout) * time.Second
+	config.FailoverCooldown = time.Duration(emailConfig.Failover_Cooldown) * time.Second
+}
@@ -0,0 +1,149 @@
+package email_helper
+
+import (
+	"fmt"
+	"golang.org/x/net/idna"
+	"net/mail"
+	"strings"
+)
+
+// ParseAddressList 按 RFC 5322 address-list 语义解析地址列表
+// 支持 "Zhang, San" <z@x.com> 这类带逗号的显示名，兼容分号分隔和多余的分隔符
+func ParseAddressList(s string) ([]mail.Address, error) {
+	var addresses []mail.Address
+	for _, item := range splitAddressList(s) {
+		address, err := mail.ParseAddress(item)
+		if err != nil {
+			return nil, fmt.Errorf("邮箱地址格式错误: %s", item)
+		}
+		normalized, err := normalizeAddress(*address)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, normalized)
+	}
+	return addresses, nil
+}
+
+// NewAddress 根据显示名和邮箱创建地址
+func NewAddress(name string, email string) (mail.Address, error) {
+	email = strings.TrimSpace(cleanHeader(email))
+	parsed, err := mail.ParseAddress(email)
+	if err != nil || parsed.Name != "" {
+		return mail.Address{}, fmt.Errorf("邮箱地址格式错误: %s", email)
+	}
+	return normalizeAddress(mail.Address{Name: strings.TrimSpace(cleanHeader(name)), Address: parsed.Address})
+}
+
+// normalizeAddress 规范化地址：国际化域名转为 punycode，域名转小写
+func normalizeAddress(address mail.Address) (mail.Address, error) {
+	i := strings.LastIndex(address.Address, "@")
+	if i <= 0 || i == len(address.Address)-1 {
+		return mail.Address{}, fmt.Errorf("邮箱地址格式错误: %s", address.Address)
+	}
+	local, domain := address.Address[:i], address.Address[i+1:]
+	if !isASCII(domain) {
+		asciiDomain, err := idna.Lookup.ToASCII(domain)
+		if err != nil {
+			return mail.Address{}, fmt.Errorf("邮箱域名不合法: %s", address.Address)
+		}
+		domain = asciiDomain
+	}
+	address.Address = local + "@" + strings.ToLower(domain)
+	address.Name = strings.TrimSpace(cleanHeader(address.Name))
+	return address, nil
+}
+
+// splitAddressList 按逗号或分号拆分地址列表，忽略引号、尖括号和注释中的分隔符
+func splitAddressList(s string) []string {
+	var items []string
+	var current strings.Builder
+	inQuote, escaped := false, false
+	angleDepth, commentDepth := 0, 0
+
+	flush := func() {
+		if item := strings.TrimSpace(current.String()); item != "" {
+			items = append(items, item)
+		}
+		current.Reset()
+	}
+
+	for _, r := range s {
+		if escaped {
+			escaped = false
+			current.WriteRune(r)
+			continue
+		}
+		switch {
+		case r == '\\' && (inQuote || commentDepth > 0):
+			escaped = true
+		case r == '"' && commentDepth == 0:
+			inQuote = !inQuote
+		case inQuote:
+		case r == '(':
+			commentDepth++
+		case r == ')' && commentDepth > 0:
+			commentDepth--
+		case commentDepth > 0:
+		case r == '<':
+			angleDepth++
+		case r == '>' && angleDepth > 0:
+			angleDepth--
+		case (r == ',' || r == ';') && angleDepth == 0:
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	flush()
+	return items
+}
+
+// DedupeRecipients 去除收件人、抄送、密送中重复的地址（不区分大小写），优先保留在收件人中的地址
+func DedupeRecipients(to, cc, bcc []mail.Address) ([]mail.Address, []mail.Address, []mail.Address) {
+	seen := make(map[string]bool)
+	dedupe := func(list []mail.Address) []mail.Address {
+		var result []mail.Address
+		for _, address := range list {
+			key := strings.ToLower(address.Address)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			result = append(result, address)
+		}
+		return result
+	}
+	return dedupe(to), dedupe(cc), dedupe(bcc)
+}
+
+// FormatAddressList 格式化地址列表用于展示和记录，显示名不做编码
+func FormatAddressList(addresses []mail.Address) string {
+	var items []string
+	for _, address := range addresses {
+		if address.Name == "" {
+			items = append(items, address.Address)
+		} else {
+			items = append(items, fmt.Sprintf("%s <%s>", quotePhrase(address.Name), address.Address))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// quotePhrase 显示名包含特殊字符时加引号
+func quotePhrase(name string) string {
+	if strings.ContainsAny(name, "()<>[]:;@\\,.\"") {
+		return quoteParam(name)
+	}
+	return name
+}
+
+// needSMTPUTF8 判断地址是否需要 SMTPUTF8 扩展（本地部分包含非 ASCII 字符）
+func needSMTPUTF8(addresses ...string) bool {
+	for _, address := range addresses {
+		if !isASCII(address) {
+			return true
+		}
+	}
+	return false
+}
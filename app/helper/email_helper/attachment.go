@@ -0,0 +1,70 @@
+package email_helper
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// Attachment 邮件附件
+type Attachment struct {
+	Filename    string // 文件名
+	ContentType string // 文件类型，为空时根据文件名和内容自动识别
+	Content     []byte // 文件内容
+	ContentID   string // 内联资源的 Content-ID，非空时以 inline 方式写入
+}
+
+// NewAttachment 创建附件，未指定类型时自动识别
+func NewAttachment(filename string, content []byte, contentType string) Attachment {
+	filename = cleanHeader(filepath.Base(filename))
+	if contentType == "" {
+		contentType = DetectContentType(filename, content)
+	}
+	return Attachment{
+		Filename:    filename,
+		ContentType: cleanHeader(contentType),
+		Content:     content,
+	}
+}
+
+// DetectContentType 识别文件类型，优先按扩展名，识别不到再按内容嗅探
+func DetectContentType(filename string, content []byte) string {
+	if ext := filepath.Ext(filename); ext != "" {
+		if contentType := mime.TypeByExtension(strings.ToLower(ext)); contentType != "" {
+			return contentType
+		}
+	}
+	return http.DetectContentType(content)
+}
+
+// isASCII 判断字符串是否只包含可打印 ASCII 字符
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteParam 转义参数值中的引号和反斜杠
+func quoteParam(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// encodeRFC2231 按 RFC 2231/5987 对参数值做百分号编码
+func encodeRFC2231(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return sb.String()
+}
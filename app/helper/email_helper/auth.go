@@ -0,0 +1,174 @@
+package email_helper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTP 认证方式
+const (
+	AuthAuto    = "auto"     // 根据服务器 EHLO 声明的 AUTH 自动选择
+	AuthPlain   = "plain"    // AUTH PLAIN
+	AuthLogin   = "login"    // AUTH LOGIN（Exchange/Office365 等）
+	AuthCRAMMD5 = "cram-md5" // AUTH CRAM-MD5
+	AuthXOAuth2 = "xoauth2"  // AUTH XOAUTH2（Gmail、Office365 的 OAuth2 认证）
+	AuthNone    = "none"     // 不认证
+)
+
+// ResolveAuthMechanism 校验并规范化认证方式配置，为空时为 auto
+func ResolveAuthMechanism(mechanism string) (string, error) {
+	mechanism = strings.ToLower(strings.TrimSpace(mechanism))
+	switch mechanism {
+	case "":
+		return AuthAuto, nil
+	case AuthAuto, AuthPlain, AuthLogin, AuthCRAMMD5, AuthXOAuth2, AuthNone:
+		return mechanism, nil
+	}
+	return "", fmt.Errorf("不支持的认证方式: %s，可选 auto、plain、login、cram-md5、xoauth2、none", mechanism)
+}
+
+// authenticate 按配置的认证方式进行 SMTP 认证
+func (c *smtpConn) authenticate(ctx context.Context, config EmailConfig) error {
+	mechanism, err := ResolveAuthMechanism(config.AuthMechanism)
+	if err != nil {
+		return err
+	}
+	if mechanism == AuthNone {
+		return nil
+	}
+
+	ok, params := c.client.Extension("AUTH")
+	supported := make(map[string]bool)
+	for _, item := range strings.Fields(strings.ToLower(params)) {
+		supported[item] = true
+	}
+
+	if mechanism == AuthAuto {
+		// 未配置账号或服务器不要求认证时跳过
+		if config.Username == "" || !ok {
+			return nil
+		}
+		mechanism = negotiateAuthMechanism(config, supported)
+		if mechanism == "" {
+			return fmt.Errorf("SMTP 服务器支持的认证方式（%s）均不可用", params)
+		}
+	} else if !supported[mechanism] {
+		return fmt.Errorf("SMTP 服务器不支持 %s 认证，服务器支持: %s", strings.ToUpper(mechanism), params)
+	}
+
+	var auth smtp.Auth
+	var source TokenSource
+	switch mechanism {
+	case AuthPlain:
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	case AuthLogin:
+		auth = &loginAuth{username: config.Username, password: config.Password, host: config.Host}
+	case AuthCRAMMD5:
+		auth = smtp.CRAMMD5Auth(config.Username, config.Password)
+	case AuthXOAuth2:
+		if source = getTokenSource(config); source == nil {
+			return errNoTokenSource
+		}
+		token, err := source.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("获取 OAuth2 访问令牌失败: %w", err)
+		}
+		auth = &xoauth2Auth{username: config.Username, token: token, host: config.Host}
+	}
+
+	c.setDeadline(c.commandTimeout)
+	err = c.client.Auth(auth)
+	// 令牌被服务器拒绝（如已被吊销），清除缓存，下次发送时重新获取
+	if refresher, ok := source.(*refreshTokenSource); ok && err != nil {
+		refresher.invalidate()
+	}
+	return err
+}
+
+// negotiateAuthMechanism 自动选择认证方式：配置了 OAuth2 时优先 XOAUTH2，其次 PLAIN、LOGIN、CRAM-MD5
+func negotiateAuthMechanism(config EmailConfig, supported map[string]bool) string {
+	if supported[AuthXOAuth2] && getTokenSource(config) != nil {
+		return AuthXOAuth2
+	}
+	for _, mechanism := range []string{AuthPlain, AuthLogin, AuthCRAMMD5} {
+		if supported[mechanism] && config.Password != "" {
+			return mechanism
+		}
+	}
+	return ""
+}
+
+// loginAuth AUTH LOGIN 认证，net/smtp 未内置
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkAuthServer(server, a.host); err != nil {
+		return "", nil, err
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	// 服务器依次询问 Username: 和 Password:
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("AUTH LOGIN 未知的服务器响应: %s", fromServer)
+}
+
+// xoauth2Auth AUTH XOAUTH2 认证
+type xoauth2Auth struct {
+	username string
+	token    string
+	host     string
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkAuthServer(server, a.host); err != nil {
+		return "", nil, err
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		// 认证失败时服务器返回 JSON 格式的错误详情，回复空行后服务器返回最终错误
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// checkAuthServer 与 smtp.PlainAuth 一致，只允许在 TLS 连接或本机服务器上发送凭证
+func checkAuthServer(server *smtp.ServerInfo, host string) error {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return errors.New("未加密的连接不允许发送认证信息")
+	}
+	if server.Name != host {
+		return errors.New("SMTP 服务器名称不匹配")
+	}
+	return nil
+}
+
+// isLocalhost 判断是否为本机地址
+func isLocalhost(name string) bool {
+	if name == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(name)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,217 @@
+package email_helper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAuthServer 测试用的本机 SMTP 服务器，只实现 EHLO、AUTH、NOOP 和 QUIT
+// auth 处理 AUTH 命令（args 为命令参数）中间的 334 交互，返回是否认证成功
+type fakeAuthServer struct {
+	listener   net.Listener
+	mechanisms string
+	auth       func(t *testing.T, tp *textproto.Conn, args []string) bool
+}
+
+func startFakeAuthServer(t *testing.T, mechanisms string, auth func(t *testing.T, tp *textproto.Conn, args []string) bool) *fakeAuthServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeAuthServer{listener: listener, mechanisms: mechanisms, auth: auth}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(t, conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeAuthServer) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			tp.PrintfLine("500 5.5.2 empty command")
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "EHLO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH %s", s.mechanisms)
+		case "AUTH":
+			if s.auth(t, tp, fields[1:]) {
+				tp.PrintfLine("235 2.7.0 Authentication successful")
+			} else {
+				tp.PrintfLine("535 5.7.8 Authentication credentials invalid")
+			}
+		case "QUIT":
+			tp.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			tp.PrintfLine("250 2.0.0 OK")
+		}
+	}
+}
+
+// config 连接该服务器的配置，本机地址允许明文发送凭证
+func (s *fakeAuthServer) config(mechanism string) EmailConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return EmailConfig{
+		Account:        "auth-test",
+		Host:           "127.0.0.1",
+		Port:           addr.Port,
+		Username:       "user@example.com",
+		Password:       "secret",
+		TLSMode:        TLSModeNone,
+		AuthMechanism:  mechanism,
+		ConnectTimeout: 2 * time.Second,
+		CommandTimeout: 2 * time.Second,
+	}
+}
+
+// dialAuth 建立会话（含认证）后立即关闭，返回认证结果
+func dialAuth(config EmailConfig) error {
+	conn, err := dialSMTP(context.Background(), config)
+	if err != nil {
+		return err
+	}
+	conn.quit()
+	conn.close()
+	return nil
+}
+
+// readAuthLine 读取客户端回复的 base64 内容
+func readAuthLine(t *testing.T, tp *textproto.Conn) string {
+	line, err := tp.ReadLine()
+	if err != nil {
+		t.Errorf("读取认证响应失败: %v", err)
+		return ""
+	}
+	data, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		t.Errorf("认证响应不是 base64: %q", line)
+	}
+	return string(data)
+}
+
+func TestAuthLogin(t *testing.T) {
+	server := startFakeAuthServer(t, "LOGIN", func(t *testing.T, tp *textproto.Conn, args []string) bool {
+		if len(args) != 1 || strings.ToUpper(args[0]) != "LOGIN" {
+			t.Errorf("AUTH 参数 = %v", args)
+			return false
+		}
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Username:")))
+		username := readAuthLine(t, tp)
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte("Password:")))
+		password := readAuthLine(t, tp)
+		return username == "user@example.com" && password == "secret"
+	})
+
+	if err := dialAuth(server.config(AuthLogin)); err != nil {
+		t.Fatalf("LOGIN 认证失败: %v", err)
+	}
+	// 未指定认证方式时根据服务器声明自动选择
+	if err := dialAuth(server.config(AuthAuto)); err != nil {
+		t.Fatalf("auto 认证失败: %v", err)
+	}
+
+	config := server.config(AuthLogin)
+	config.Password = "wrong"
+	if err := dialAuth(config); replyCode(err) != 535 {
+		t.Errorf("密码错误时应返回 535，实际: %v", err)
+	}
+	// 服务器未声明的认证方式直接报错
+	if err := dialAuth(server.config(AuthCRAMMD5)); err == nil || !strings.Contains(err.Error(), "不支持") {
+		t.Errorf("服务器不支持 CRAM-MD5 时应报错，实际: %v", err)
+	}
+}
+
+func TestAuthCRAMMD5(t *testing.T) {
+	const challenge = "<1896.697170952@localhost>"
+	server := startFakeAuthServer(t, "CRAM-MD5", func(t *testing.T, tp *textproto.Conn, args []string) bool {
+		if len(args) != 1 || strings.ToUpper(args[0]) != "CRAM-MD5" {
+			t.Errorf("AUTH 参数 = %v", args)
+			return false
+		}
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+		username, digest, _ := strings.Cut(readAuthLine(t, tp), " ")
+		mac := hmac.New(md5.New, []byte("secret"))
+		mac.Write([]byte(challenge))
+		return username == "user@example.com" && digest == hex.EncodeToString(mac.Sum(nil))
+	})
+
+	if err := dialAuth(server.config(AuthCRAMMD5)); err != nil {
+		t.Fatalf("CRAM-MD5 认证失败: %v", err)
+	}
+	config := server.config(AuthCRAMMD5)
+	config.Password = "wrong"
+	if err := dialAuth(config); replyCode(err) != 535 {
+		t.Errorf("密码错误时应返回 535，实际: %v", err)
+	}
+}
+
+// xoauth2Server 校验 XOAUTH2 初始响应，令牌无效时按 Gmail 的方式先返回 334 和 JSON 错误详情，客户端回复空行后返回 535
+func xoauth2Server(valid func(token string) bool) func(t *testing.T, tp *textproto.Conn, args []string) bool {
+	return func(t *testing.T, tp *textproto.Conn, args []string) bool {
+		if len(args) != 2 || strings.ToUpper(args[0]) != "XOAUTH2" {
+			t.Errorf("AUTH 参数 = %v", args)
+			return false
+		}
+		data, _ := base64.StdEncoding.DecodeString(args[1])
+		token := strings.TrimSuffix(strings.TrimPrefix(string(data), "user=user@example.com\x01auth=Bearer "), "\x01\x01")
+		if valid(token) && string(data) == "user=user@example.com\x01auth=Bearer "+token+"\x01\x01" {
+			return true
+		}
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(`{"status":"401","schemes":"bearer","scope":"https://mail.google.com/"}`)))
+		if line, err := tp.ReadLine(); err != nil || line != "" {
+			t.Errorf("客户端应回复空行，实际: %q, %v", line, err)
+		}
+		return false
+	}
+}
+
+func TestAuthXOAuth2StaticToken(t *testing.T) {
+	server := startFakeAuthServer(t, "PLAIN XOAUTH2", xoauth2Server(func(token string) bool { return token == "ya29.valid" }))
+
+	config := server.config(AuthXOAuth2)
+	config.OAuth2AccessToken = "ya29.valid"
+	if err := dialAuth(config); err != nil {
+		t.Fatalf("XOAUTH2 认证失败: %v", err)
+	}
+	// 配置了 OAuth2 时 auto 优先选择 XOAUTH2
+	config.AuthMechanism = AuthAuto
+	config.Password = ""
+	if err := dialAuth(config); err != nil {
+		t.Fatalf("auto 应选择 XOAUTH2: %v", err)
+	}
+
+	config.OAuth2AccessToken = "ya29.revoked"
+	if err := dialAuth(config); replyCode(err) != 535 {
+		t.Errorf("令牌无效时应返回 535，实际: %v", err)
+	}
+	if err := dialAuth(server.config(AuthXOAuth2)); !errors.Is(err, errNoTokenSource) {
+		t.Errorf("未配置令牌时应返回 errNoTokenSource，实际: %v", err)
+	}
+}
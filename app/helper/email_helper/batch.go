@@ -0,0 +1,44 @@
+package email_helper
+
+import (
+	"gin_base/app/helper/helper"
+	"sync"
+)
+
+const (
+	// 批量发送单次最多的邮件数
+	MaxBatchSize = 500
+	// 批量发送默认同时发送的邮件数
+	defaultBatchConcurrency = 5
+)
+
+// BatchConcurrency 批量发送同时发送的邮件数，读取 app/appconfig/email.yaml
+func BatchConcurrency() int {
+	if concurrency := helper.GetAppConfig().Email.Batch_Concurrency; concurrency > 0 {
+		return concurrency
+	}
+	return defaultBatchConcurrency
+}
+
+// RunBatch 并发执行 fn(0) 到 fn(n-1)，同时执行的数量不超过 concurrency，全部完成后返回
+func RunBatch(n int, concurrency int, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
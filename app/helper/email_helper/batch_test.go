@@ -0,0 +1,34 @@
+package email_helper
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunBatch(t *testing.T) {
+	var mutex sync.Mutex
+	running, peak := 0, 0
+	done := make([]bool, 20)
+	RunBatch(len(done), 3, func(i int) {
+		mutex.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mutex.Lock()
+		running--
+		done[i] = true
+		mutex.Unlock()
+	})
+	if peak > 3 {
+		t.Errorf("同时执行数 = %d，不应超过 3", peak)
+	}
+	for i, ok := range done {
+		if !ok {
+			t.Errorf("第 %d 项未执行", i)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package email_helper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	// 内置时区数据，scratch 镜像中没有 /usr/share/zoneinfo
+	_ "time/tzdata"
+	"unicode/utf8"
+)
+
+// 日历邀请方法（iTIP，RFC 5546）
+const (
+	CalendarMethodRequest = "REQUEST" // 邀请或更新
+	CalendarMethodCancel  = "CANCEL"  // 取消
+)
+
+const (
+	// ics 内容行的最大字节数，超出时折行（RFC 5545 3.1）
+	icsLineLength = 75
+	// ics 附件文件名
+	calendarFilename = "invite.ics"
+)
+
+// CalendarEvent 日历邀请，发送时生成 text/calendar 部分和 .ics 附件，Outlook、Gmail 会显示接受/拒绝按钮
+type CalendarEvent struct {
+	Method      string         // REQUEST 或 CANCEL，为空时为 REQUEST
+	UID         string         // 事件唯一标识，更新和取消同一事件时必须相同
+	Sequence    int            // 版本号，每次更新或取消时递增
+	Summary     string         // 标题，为空时使用邮件主题
+	Description string         // 描述
+	Location    string         // 地点
+	Start       time.Time      // 开始时间
+	End         time.Time      // 结束时间
+	Timezone    string         // IANA 时区，如 Asia/Shanghai，为空时使用环境变量 TZ，默认 Asia/Shanghai
+	Organizer   mail.Address   // 组织者，为空时使用发件人
+	Attendees   []mail.Address // 参会人，为空时使用收件人和抄送
+}
+
+// CalendarLocation 加载日历时区，为空时使用环境变量 TZ，默认 Asia/Shanghai
+func CalendarLocation(timezone string) (*time.Location, error) {
+	timezone = strings.TrimSpace(timezone)
+	if timezone == "" {
+		timezone = os.Getenv("TZ")
+	}
+	if timezone == "" {
+		timezone = "Asia/Shanghai"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		return nil, fmt.Errorf("不支持的时区: %s", timezone)
+	}
+	return loc, nil
+}
+
+// NormalizeCalendarEvent 校验日历邀请并补全 method 和时区
+func NormalizeCalendarEvent(event *CalendarEvent) error {
+	event.Method = strings.ToUpper(strings.TrimSpace(event.Method))
+	if event.Method == "" {
+		event.Method = CalendarMethodRequest
+	}
+	if event.Method != CalendarMethodRequest && event.Method != CalendarMethodCancel {
+		return errors.New("日历邀请 method 只能为 REQUEST 或 CANCEL")
+	}
+	event.UID = strings.TrimSpace(event.UID)
+	if event.UID == "" {
+		return errors.New("日历邀请 uid 不能为空")
+	}
+	if strings.ContainsAny(event.UID, "\r\n") || len(event.UID) > 255 {
+		return errors.New("日历邀请 uid 格式错误")
+	}
+	if event.Sequence < 0 {
+		return errors.New("日历邀请 sequence 不能小于 0")
+	}
+	if event.Start.IsZero() || event.End.IsZero() {
+		return errors.New("日历邀请开始时间和结束时间不能为空")
+	}
+	if !event.End.After(event.Start) {
+		return errors.New("日历邀请结束时间必须晚于开始时间")
+	}
+	loc, err := CalendarLocation(event.Timezone)
+	if err != nil {
+		return err
+	}
+	event.Timezone = loc.String()
+	return nil
+}
+
+// NewCalendarPart 创建 text/calendar 部分，放在 multipart/alternative 中
+// ics 使用 \r\n 换行，以 base64 传输保证换行不被修改
+func NewCalendarPart(method string, ics []byte) *MIMEPart {
+	part := &MIMEPart{Body: ics, Encoding: EncodingBase64}
+	part.Header.SetWithParams("Content-Type", "text/calendar",
+		MIMEParam{Name: "charset", Value: "UTF-8"}, MIMEParam{Name: "method", Value: method})
+	return part
+}
+
+// RenderCalendar 生成 iCalendar 内容（RFC 5545），now 为生成时间（DTSTAMP）
+// event 需要先经过 NormalizeCalendarEvent 校验，组织者和参会人为空时不写入
+func RenderCalendar(event CalendarEvent, now time.Time) []byte {
+	loc, err := CalendarLocation(event.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "PRODID:-//Email Tool//Calendar//ZH")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+	writeICSLine(&buf, "METHOD:"+event.Method)
+	if loc != time.UTC {
+		writeVTimezone(&buf, loc, event.Start.In(loc).Year())
+	}
+
+	writeICSLine(&buf, "BEGIN:VEVENT")
+	writeICSLine(&buf, "UID:"+icsText(event.UID))
+	writeICSLine(&buf, "DTSTAMP:"+now.UTC().Format("20060102T150405Z"))
+	writeICSLine(&buf, "SEQUENCE:"+strconv.Itoa(event.Sequence))
+	writeICSLine(&buf, "DTSTART"+icsDateTime(event.Start, loc))
+	writeICSLine(&buf, "DTEND"+icsDateTime(event.End, loc))
+	writeICSLine(&buf, "SUMMARY:"+icsText(event.Summary))
+	if event.Description != "" {
+		writeICSLine(&buf, "DESCRIPTION:"+icsText(event.Description))
+	}
+	if event.Location != "" {
+		writeICSLine(&buf, "LOCATION:"+icsText(event.Location))
+	}
+	if event.Organizer.Address != "" {
+		writeICSLine(&buf, "ORGANIZER"+icsCommonName(event.Organizer.Name)+":mailto:"+event.Organizer.Address)
+	}
+	for _, attendee := range event.Attendees {
+		params := icsCommonName(attendee.Name) + ";CUTYPE=INDIVIDUAL;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION"
+		if event.Method == CalendarMethodRequest {
+			params += ";RSVP=TRUE"
+		}
+		writeICSLine(&buf, "ATTENDEE"+params+":mailto:"+attendee.Address)
+	}
+	if event.Method == CalendarMethodCancel {
+		writeICSLine(&buf, "STATUS:CANCELLED")
+	} else {
+		writeICSLine(&buf, "STATUS:CONFIRMED")
+	}
+	writeICSLine(&buf, "TRANSP:OPAQUE")
+	writeICSLine(&buf, "END:VEVENT")
+	writeICSLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// writeVTimezone 写入时区定义，包含 year 年内的夏令时切换规则
+func writeVTimezone(buf *bytes.Buffer, loc *time.Location, year int) {
+	writeICSLine(buf, "BEGIN:VTIMEZONE")
+	writeICSLine(buf, "TZID:"+loc.String())
+
+	transitions := zoneTransitions(loc, year)
+	if len(transitions) == 0 {
+		name, offset := time.Date(year, 1, 1, 0, 0, 0, 0, loc).Zone()
+		writeICSLine(buf, "BEGIN:STANDARD")
+		writeICSLine(buf, "DTSTART:19700101T000000")
+		writeICSLine(buf, "TZOFFSETFROM:"+icsOffset(offset))
+		writeICSLine(buf, "TZOFFSETTO:"+icsOffset(offset))
+		writeICSLine(buf, "TZNAME:"+icsText(name))
+		writeICSLine(buf, "END:STANDARD")
+	}
+	for _, transition := range transitions {
+		_, from := transition.Add(-time.Second).Zone()
+		name, to := transition.Zone()
+		component := "STANDARD"
+		if transition.IsDST() {
+			component = "DAYLIGHT"
+		}
+		// DTSTART 为切换前的本地时间
+		local := transition.In(time.FixedZone("", from))
+		writeICSLine(buf, "BEGIN:"+component)
+		writeICSLine(buf, "DTSTART:"+local.Format("20060102T150405"))
+		// 每年两次切换时按"第 n 个星期几"生成每年重复的规则，最后一周使用 -1
+		if len(transitions) == 2 {
+			week := strconv.Itoa((local.Day()-1)/7 + 1)
+			if local.AddDate(0, 0, 7).Month() != local.Month() {
+				week = "-1"
+			}
+			weekday := []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}[local.Weekday()]
+			writeICSLine(buf, fmt.Sprintf("RRULE:FREQ=YEARLY;BYMONTH=%d;BYDAY=%s%s", local.Month(), week, weekday))
+		}
+		writeICSLine(buf, "TZOFFSETFROM:"+icsOffset(from))
+		writeICSLine(buf, "TZOFFSETTO:"+icsOffset(to))
+		writeICSLine(buf, "TZNAME:"+icsText(name))
+		writeICSLine(buf, "END:"+component)
+	}
+	writeICSLine(buf, "END:VTIMEZONE")
+}
+
+// zoneTransitions 查找 year 年内 UTC 偏移变化的时刻（夏令时切换）
+func zoneTransitions(loc *time.Location, year int) []time.Time {
+	var transitions []time.Time
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(year+1, 1, 1, 0, 0, 0, 0, loc)
+	_, prev := start.Zone()
+	for t := start; t.Before(end); t = t.Add(24 * time.Hour) {
+		next := t.Add(24 * time.Hour)
+		_, offset := next.Zone()
+		if offset == prev {
+			continue
+		}
+		// 二分查找切换时刻，精确到秒
+		lo, hi := t, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.Zone(); o == prev {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		transitions = append(transitions, hi)
+		prev = offset
+	}
+	return transitions
+}
+
+// icsDateTime 格式化时间属性的参数和值，UTC 时使用 Z 后缀，其他时区使用 TZID
+func icsDateTime(t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return ":" + t.UTC().Format("20060102T150405Z")
+	}
+	return ";TZID=" + icsParam(loc.String()) + ":" + t.In(loc).Format("20060102T150405")
+}
+
+// icsOffset 格式化 UTC 偏移，如 +0800、-0430
+func icsOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	s := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+	if offset%60 != 0 {
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+	return s
+}
+
+// icsCommonName 生成 CN 参数，名称为空时返回空
+func icsCommonName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return ";CN=" + icsParam(name)
+}
+
+// icsText 转义 TEXT 类型的值：反斜杠、分号、逗号和换行
+func icsText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// icsParam 格式化参数值，参数值不能包含双引号和控制字符，包含 : ; , 时加引号
+func icsParam(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '"' || r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+	if strings.ContainsAny(s, ":;,") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// writeICSLine 写入内容行，超过 75 字节时折行，折行不拆分 UTF-8 字符，使用 \r\n 换行
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := icsLineLength
+	for len(line) > limit {
+		end := limit
+		for end > 0 && !utf8.RuneStart(line[end]) {
+			end--
+		}
+		buf.WriteString(line[:end] + "\r\n ")
+		line = line[end:]
+		// 续行开头的空格占用一个字节
+		limit = icsLineLength - 1
+	}
+	buf.WriteString(line + "\r\n")
+}
@@ -0,0 +1,77 @@
+package email_helper
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// Charset 邮件字符集，用于兼容不能正确显示 UTF-8 的旧邮件客户端
+type Charset struct {
+	Name     string            // MIME 字符集名称，如 GB18030、GBK、Big5、ISO-2022-JP
+	encoding encoding.Encoding // UTF-8 时为 nil
+}
+
+// CharsetUTF8 默认字符集
+var CharsetUTF8 = &Charset{Name: "UTF-8"}
+
+// LookupCharset 根据名称或别名（如 gb2312、big5、iso-2022-jp）查找字符集，为空时返回 UTF-8
+func LookupCharset(name string) (*Charset, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return CharsetUTF8, nil
+	}
+
+	// 优先使用 IANA 名称，找不到时按 WHATWG 的别名查找（如 gb2312 对应 GBK）
+	enc, _ := ianaindex.MIME.Encoding(name)
+	if enc == nil {
+		enc, _ = htmlindex.Get(name)
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("不支持的字符集: %s", name)
+	}
+	mimeName, err := ianaindex.MIME.Name(enc)
+	if err != nil {
+		return nil, fmt.Errorf("不支持的字符集: %s", name)
+	}
+	if strings.EqualFold(mimeName, "UTF-8") {
+		return CharsetUTF8, nil
+	}
+
+	// 信头和分隔符都是 ASCII，只支持兼容 ASCII 的字符集（排除 UTF-16 等）
+	if encoded, err := enc.NewEncoder().String("A=?\n"); err != nil || encoded != "A=?\n" {
+		return nil, fmt.Errorf("字符集 %s 不兼容 ASCII，不能用于邮件", mimeName)
+	}
+	return &Charset{Name: mimeName, encoding: enc}, nil
+}
+
+// IsUTF8 是否为 UTF-8
+func (c *Charset) IsUTF8() bool {
+	return c == nil || c.encoding == nil
+}
+
+// Encode 将文本转换为该字符集，包含无法表示的字符时返回错误
+func (c *Charset) Encode(s string) ([]byte, error) {
+	if c.IsUTF8() {
+		return []byte(s), nil
+	}
+	// 每次使用新的编码器，ISO-2022-JP 等有状态的编码在结尾切换回 ASCII
+	encoded, err := c.encoding.NewEncoder().String(s)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(encoded), nil
+}
+
+// CanEncode 判断所有文本能否用该字符集表示
+func (c *Charset) CanEncode(texts ...string) bool {
+	for _, s := range texts {
+		if _, err := c.Encode(s); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,338 @@
+package email_helper
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"gin_base/app/helper/helper"
+	"os"
+	"strings"
+	"time"
+)
+
+// DKIM 签名算法
+const (
+	DKIMAlgorithmRSA     = "rsa"
+	DKIMAlgorithmEd25519 = "ed25519"
+)
+
+// 默认签名的信头，存在时才会签名
+var defaultDKIMHeaders = []string{
+	"From", "Reply-To", "Subject", "Date", "To", "Cc", "Message-ID",
+	"In-Reply-To", "References", "MIME-Version", "Content-Type",
+	"List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+// DKIMConfig 发件域名的 DKIM 签名配置
+type DKIMConfig struct {
+	Domain   string
+	Selector string
+	Signer   crypto.Signer // *rsa.PrivateKey 或 ed25519.PrivateKey
+	Headers  []string      // 签名的信头
+}
+
+// GetDKIMConfig 获取发件域名的 DKIM 配置（app/appconfig/dkim.yaml），未配置时返回 nil
+func GetDKIMConfig(domain string) (*DKIMConfig, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	dkimConfig := helper.GetAppConfig().Dkim
+	for _, item := range dkimConfig.Domains {
+		if strings.ToLower(strings.TrimSpace(item.Domain)) != domain {
+			continue
+		}
+
+		selector := strings.TrimSpace(item.Selector)
+		if selector == "" {
+			return nil, fmt.Errorf("域名 %s 的 DKIM selector 未配置", domain)
+		}
+		keyPEM := []byte(strings.TrimSpace(item.Private_Key))
+		if len(keyPEM) == 0 {
+			if item.Private_Key_File == "" {
+				return nil, fmt.Errorf("域名 %s 的 DKIM 私钥未配置", domain)
+			}
+			var err error
+			if keyPEM, err = os.ReadFile(item.Private_Key_File); err != nil {
+				return nil, fmt.Errorf("读取 DKIM 私钥失败: %v", err)
+			}
+		}
+		signer, err := ParseDKIMPrivateKey(keyPEM)
+		if err != nil {
+			return nil, err
+		}
+
+		headers := item.Headers
+		if len(headers) == 0 {
+			headers = dkimConfig.Headers
+		}
+		if len(headers) == 0 {
+			headers = defaultDKIMHeaders
+		}
+		return &DKIMConfig{Domain: domain, Selector: selector, Signer: signer, Headers: headers}, nil
+	}
+	return nil, nil
+}
+
+// ParseDKIMPrivateKey 解析 PEM 格式的 RSA（PKCS#1、PKCS#8）或 Ed25519（PKCS#8）私钥
+func ParseDKIMPrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("DKIM 私钥格式错误，需要 PEM 格式")
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("DKIM 私钥解析失败: %v", err)
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, errors.New("DKIM 私钥只支持 RSA 和 Ed25519")
+}
+
+// SignDKIM 对邮件进行 DKIM 签名（relaxed/relaxed），返回带 DKIM-Signature 信头的邮件
+// msg 使用 \n 或 \r\n 换行，返回内容保持 \n 换行
+func SignDKIM(msg []byte, config DKIMConfig) ([]byte, error) {
+	var algorithm string
+	switch config.Signer.(type) {
+	case *rsa.PrivateKey:
+		algorithm = "rsa-sha256"
+	case ed25519.PrivateKey:
+		algorithm = "ed25519-sha256"
+	default:
+		return nil, errors.New("DKIM 私钥只支持 RSA 和 Ed25519")
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(msg), "\r\n", "\n"), "\n")
+	var headerLines, bodyLines []string
+	for i, line := range lines {
+		if line == "" {
+			headerLines, bodyLines = lines[:i], lines[i+1:]
+			break
+		}
+	}
+	if headerLines == nil {
+		return nil, errors.New("邮件缺少信头与正文之间的空行")
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(bodyLines))
+
+	// 同名信头从下往上依次签名；配置中重复的信头名称超出实际数量时仍写入 h=（oversign），防止被追加同名信头
+	fields := parseHeaderFields(headerLines)
+	used := make(map[int]bool)
+	listed := make(map[string]bool)
+	var signedNames []string
+	var signedFields []string
+	for _, name := range config.Headers {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for i := len(fields) - 1; i >= 0; i-- {
+			if used[i] || !strings.EqualFold(fields[i].name, name) {
+				continue
+			}
+			used[i] = true
+			found = true
+			signedNames = append(signedNames, name)
+			signedFields = append(signedFields, fields[i].raw)
+			break
+		}
+		if !found && listed[name] {
+			signedNames = append(signedNames, name)
+		}
+		listed[name] = true
+	}
+	hasFrom := false
+	for _, name := range signedNames {
+		if name == "from" {
+			hasFrom = true
+		}
+	}
+	if !hasFrom {
+		return nil, errors.New("DKIM 签名必须包含 From 信头")
+	}
+
+	// b= 留空计算签名，签名后追加到末尾
+	signature := fmt.Sprintf("DKIM-Signature: v=1; a=%s; c=relaxed/relaxed;\n\td=%s; s=%s; t=%d;\n\th=%s;\n\tbh=%s;\n\tb=",
+		algorithm, config.Domain, config.Selector, time.Now().Unix(),
+		foldDKIMList(signedNames), base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	var data bytes.Buffer
+	for _, field := range signedFields {
+		data.WriteString(relaxedHeader(field))
+		data.WriteString("\r\n")
+	}
+	data.WriteString(relaxedHeader(signature))
+
+	hash := sha256.Sum256(data.Bytes())
+	var sig []byte
+	var err error
+	if key, ok := config.Signer.(ed25519.PrivateKey); ok {
+		// RFC 8463：Ed25519 对 SHA-256 摘要签名
+		sig = ed25519.Sign(key, hash[:])
+	} else {
+		sig, err = config.Signer.Sign(rand.Reader, hash[:], crypto.SHA256)
+		if err != nil {
+			return nil, fmt.Errorf("DKIM 签名失败: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(signature)
+	encoded := base64.StdEncoding.EncodeToString(sig)
+	for i := 0; i < len(encoded); i += 72 {
+		end := i + 72
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		if i > 0 {
+			buf.WriteString("\n\t ")
+		}
+		buf.WriteString(encoded[i:end])
+	}
+	buf.WriteString("\n")
+	buf.WriteString(strings.ReplaceAll(string(msg), "\r\n", "\n"))
+	return buf.Bytes(), nil
+}
+
+// headerField 信头字段，raw 为包含折行的原始内容
+type headerField struct {
+	name string
+	raw  string
+}
+
+// parseHeaderFields 解析信头，以空白开头的行是上一个信头的折行
+func parseHeaderFields(lines []string) []headerField {
+	var fields []headerField
+	for _, line := range lines {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(fields) > 0 {
+			fields[len(fields)-1].raw += "\n" + line
+			continue
+		}
+		name := line
+		if i := strings.Index(line, ":"); i >= 0 {
+			name = line[:i]
+		}
+		fields = append(fields, headerField{name: strings.TrimSpace(name), raw: line})
+	}
+	return fields
+}
+
+// relaxedHeader relaxed 信头规范化：名称小写，去掉折行，连续空白合并为一个空格，去掉冒号两侧和末尾的空白
+func relaxedHeader(field string) string {
+	field = strings.ReplaceAll(field, "\r", "")
+	field = strings.ReplaceAll(field, "\n", "")
+	name, value, _ := strings.Cut(field, ":")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.TrimSpace(collapseWhitespace(value))
+}
+
+// relaxedBody relaxed 正文规范化：每行去掉末尾空白、连续空白合并为一个空格，去掉末尾空行，使用 \r\n 换行
+func relaxedBody(lines []string) []byte {
+	end := len(lines)
+	canonical := make([]string, len(lines))
+	for i, line := range lines {
+		canonical[i] = strings.TrimRight(collapseWhitespace(strings.TrimSuffix(line, "\r")), " ")
+	}
+	for end > 0 && canonical[end-1] == "" {
+		end--
+	}
+	var buf bytes.Buffer
+	for _, line := range canonical[:end] {
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+// collapseWhitespace 将连续的空格和制表符合并为一个空格
+func collapseWhitespace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// foldDKIMList 拼接 h= 的信头列表，过长时折行
+func foldDKIMList(names []string) string {
+	var b strings.Builder
+	lineLen := 3
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(":")
+			lineLen++
+			if lineLen+len(name) > 72 {
+				b.WriteString("\n\t ")
+				lineLen = 2
+			}
+		}
+		b.WriteString(name)
+		lineLen += len(name)
+	}
+	return b.String()
+}
+
+// GenerateDKIMKey 生成 DKIM 密钥对，返回 PKCS#8 PEM 格式私钥和需要发布的 DNS TXT 记录值
+func GenerateDKIMKey(algorithm string, bits int) ([]byte, string, error) {
+	var privateKey interface{}
+	var publicKey []byte
+	var keyType string
+	switch strings.ToLower(algorithm) {
+	case DKIMAlgorithmRSA:
+		if bits < 1024 {
+			return nil, "", errors.New("RSA 密钥长度不能小于 1024")
+		}
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return nil, "", err
+		}
+		if publicKey, err = x509.MarshalPKIXPublicKey(&key.PublicKey); err != nil {
+			return nil, "", err
+		}
+		privateKey, keyType = key, "rsa"
+	case DKIMAlgorithmEd25519:
+		public, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, "", err
+		}
+		// RFC 8463：Ed25519 公钥直接使用 32 字节原始公钥
+		publicKey, privateKey, keyType = public, key, "ed25519"
+	default:
+		return nil, "", fmt.Errorf("不支持的 DKIM 算法: %s，可选 rsa、ed25519", algorithm)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, "", err
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	record := fmt.Sprintf("v=DKIM1; k=%s; p=%s", keyType, base64.StdEncoding.EncodeToString(publicKey))
+	return privatePEM, record, nil
+}
+
+// DKIMRecordName DKIM 公钥的 DNS 记录名称
+func DKIMRecordName(selector string, domain string) string {
+	return selector + "._domainkey." + domain
+}
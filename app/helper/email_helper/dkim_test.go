@@ -0,0 +1,155 @@
+package email_helper
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSignDKIM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name    string
+		msg     string
+		headers []string
+		h       string   // 期望的 h= 列表
+		signed  []string // 期望参与签名的 relaxed 信头，按 h= 的顺序
+		body    string   // 期望的 relaxed 正文
+	}{
+		{
+			name: "折行信头和末尾空白",
+			msg: "From: Sender <s@example.com>\r\nTo: a@example.com\r\nSubject:  Hello\r\n\t  folded   world  \r\n" +
+				"X-Other: not signed\r\n\r\nHello  World \t\r\nline2\t\r\n\r\n\r\n",
+			headers: []string{"From", "Subject", "To"},
+			h:       "from:subject:to",
+			signed:  []string{"from:Sender <s@example.com>", "subject:Hello folded world", "to:a@example.com"},
+			body:    "Hello World\r\nline2\r\n",
+		},
+		{
+			name:    "空正文",
+			msg:     "From: s@example.com\nSubject: Empty\n\n",
+			headers: []string{"From", "Subject"},
+			h:       "from:subject",
+			signed:  []string{"from:s@example.com", "subject:Empty"},
+			body:    "",
+		},
+		{
+			name:    "正文只有空行",
+			msg:     "From: s@example.com\n\n \t\n\n",
+			headers: []string{"From"},
+			h:       "from",
+			signed:  []string{"from:s@example.com"},
+			body:    "",
+		},
+		{
+			// 同名信头从下往上签名，配置中多出的同名信头写入 h= 防止被追加
+			name:    "重复信头",
+			msg:     "Received: a\nFrom: s@example.com\nReceived: b\n\nbody\n",
+			headers: []string{"From", "Received", "Received", "Received"},
+			h:       "from:received:received:received",
+			signed:  []string{"from:s@example.com", "received:b", "received:a"},
+			body:    "body\r\n",
+		},
+	}
+
+	keys := []struct {
+		name   string
+		signer crypto.Signer
+	}{
+		{"rsa", rsaKey},
+		{"ed25519", ed25519Key},
+	}
+	for _, key := range keys {
+		for _, tc := range cases {
+			config := DKIMConfig{Domain: "example.com", Selector: "mail", Signer: key.signer, Headers: tc.headers}
+			signed, err := SignDKIM([]byte(tc.msg), config)
+			if err != nil {
+				t.Errorf("%s/%s: 签名失败: %v", key.name, tc.name, err)
+				continue
+			}
+			verifyDKIM(t, key.name+"/"+tc.name, signed, key.signer.Public(), tc.h, tc.signed, tc.body)
+			if !strings.HasSuffix(string(signed), strings.ReplaceAll(tc.msg, "\r\n", "\n")) {
+				t.Errorf("%s/%s: 签名后的邮件内容被修改", key.name, tc.name)
+			}
+		}
+	}
+
+	// 缺少 From 时不能签名
+	if _, err := SignDKIM([]byte("Subject: x\n\nbody\n"), DKIMConfig{Domain: "example.com", Selector: "mail", Signer: ed25519Key, Headers: []string{"From", "Subject"}}); err == nil {
+		t.Error("缺少 From 信头时应返回错误")
+	}
+}
+
+// verifyDKIM 按 RFC 6376 独立计算 relaxed 规范化结果并用公钥校验 DKIM-Signature
+func verifyDKIM(t *testing.T, name string, signed []byte, public crypto.PublicKey, h string, headers []string, body string) {
+	t.Helper()
+	// DKIM-Signature 是第一个信头，以空白开头的行是它的折行
+	lines := strings.Split(string(signed), "\n")
+	field := lines[0]
+	for _, line := range lines[1:] {
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		field += "\n" + line
+	}
+	if !strings.HasPrefix(field, "DKIM-Signature:") {
+		t.Errorf("%s: 缺少 DKIM-Signature 信头", name)
+		return
+	}
+
+	tags := make(map[string]string)
+	value := strings.TrimPrefix(field, "DKIM-Signature:")
+	for _, tag := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(tag, "=")
+		tags[strings.TrimSpace(key)] = strings.Join(strings.Fields(val), "")
+	}
+	bodyHash := sha256.Sum256([]byte(body))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		t.Errorf("%s: bh = %s，期望正文规范化为 %q", name, tags["bh"], body)
+	}
+	if tags["h"] != h {
+		t.Errorf("%s: h = %s，期望 %s", name, tags["h"], h)
+	}
+	if tags["c"] != "relaxed/relaxed" || tags["d"] != "example.com" || tags["s"] != "mail" {
+		t.Errorf("%s: 标签错误: %v", name, tags)
+	}
+
+	// 签名数据：参与签名的信头 + b= 为空的 DKIM-Signature（relaxed，不含末尾换行）
+	unfolded := regexp.MustCompile(`[ \t]+`).ReplaceAllString(strings.ReplaceAll(field, "\n", ""), " ")
+	unfolded = "dkim-signature:" + strings.TrimSpace(strings.TrimPrefix(unfolded, "DKIM-Signature:"))
+	unfolded = regexp.MustCompile(`(;\s*b=)[^;]*$`).ReplaceAllString(unfolded, "${1}")
+	data := strings.Join(headers, "\r\n") + "\r\n" + unfolded
+	hash := sha256.Sum256([]byte(data))
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		t.Errorf("%s: b= 不是 base64: %v", name, err)
+		return
+	}
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, hash[:], sig) {
+			err = errors.New("签名不匹配")
+		}
+	}
+	if err != nil {
+		t.Errorf("%s: 签名校验失败: %v", name, err)
+	}
+}
@@ -1,12 +1,8 @@
 package email_helper
 
 import (
-	"bytes"
-	"crypto/tls"
-	"encoding/base64"
-	"fmt"
-	"mime"
-	"net/smtp"
+	"context"
+	"net/mail"
 	"os"
 	"strconv"
 	"strings"
@@ -15,27 +11,75 @@ import (
 
 // EmailConfig SMTP 配置
 type EmailConfig struct {
-	Host     string
-	Port     int
-	Username string
-	Password string
-	From     string
-	FromName string
+	Account       string // 账号名称，对应 smtp.yaml 中的配置，默认账号为 default
+	Host          string
+	Port          int
+	Username      string
+	Password      string
+	From          string
+	FromName      string
+	TLSMode       string // 加密方式：implicit、starttls、opportunistic、none，为空时按端口自动选择
+	TLSSkipVerify bool   // 是否跳过服务器证书校验
+	TLSCAFile     string // 自定义 CA 证书文件（PEM）
+	TLSMinVersion string // TLS 最低版本：1.0、1.1、1.2、1.3，默认 1.2
+	TLSCertFile   string // 客户端证书文件（mTLS）
+	TLSKeyFile    string // 客户端私钥文件（mTLS）
+
+	AuthMechanism      string // 认证方式：auto、plain、login、cram-md5、xoauth2、none，为空时为 auto
+	OAuth2AccessToken  string // XOAUTH2 固定访问令牌
+	OAuth2TokenURL     string // XOAUTH2 令牌接口，配置后自动获取并刷新访问令牌
+	OAuth2ClientID     string
+	OAuth2ClientSecret string
+	OAuth2RefreshToken string // 为空时使用 client_credentials 授权
+	OAuth2Scope        string
+
+	ConnectTimeout time.Duration // 连接超时（含 TLS 握手）
+	CommandTimeout time.Duration // 单条 SMTP 命令超时
+	DataTimeout    time.Duration // DATA 阶段传输超时
+
+	PoolSize        int           // 连接池大小，0 表示不使用连接池
+	PoolIdleTimeout time.Duration // 连接池空闲超时
+
+	Failover         []string      // 故障转移账号，当前账号连接失败或返回 4xx 临时错误时按顺序尝试
+	FailoverCooldown time.Duration // 连接失败的服务器在冷却时间内不再尝试
 }
 
 // EmailMessage 邮件内容
 type EmailMessage struct {
-	To      []string // 收件人列表
-	Cc      []string // 抄送列表
-	Subject string   // 邮件主题
-	Body    string   // 邮件正文
-	IsHTML  bool     // 是否为HTML格式
+	To          []mail.Address    // 收件人列表
+	Cc          []mail.Address    // 抄送列表
+	Bcc         []mail.Address    // 密送列表，只用于投递，不写入信头
+	ReplyTo     []mail.Address    // 回复地址
+	Priority    int               // 优先级：1-高，3-普通，5-低，0-不设置
+	Headers     map[string]string // 自定义信头，只允许 X- 开头
+	Subject     string            // 邮件主题
+	Body        string            // 邮件正文
+	TextBody    string            // 纯文本正文，HTML 邮件的备用内容，为空时根据 HTML 自动生成
+	IsHTML      bool              // 是否为HTML格式
+	Attachments []Attachment      // 附件列表
+	Inlines     []Attachment      // 内联资源，HTML 正文中通过 cid:ContentID 引用
+	Charset     string            // 目标字符集，如 GB18030、Big5、ISO-2022-JP，为空时使用 UTF-8
+	ThreadKey   string            // 会话标识，同一标识的邮件在收件人客户端中归为同一会话
+	InReplyTo   string            // 回复的 Message-ID（不含尖括号）
+	References  []string          // 同一会话中之前邮件的 Message-ID（不含尖括号），从早到晚排列
+	Calendar    *CalendarEvent    // 日历邀请
+	Category    string            // 邮件分类，如 newsletter，退订按分类记录
+	// 退订链接，设置时写入 List-Unsubscribe 和 List-Unsubscribe-Post（RFC 8058 一键退订）
+	UnsubscribeURL string
+	// 预先生成的 Message-ID（不含尖括号），为空时发送时生成；异步发送时入队即生成，重试时保持不变
+	MessageID string
 }
 
 // EmailResult 发送结果
 type EmailResult struct {
-	Success bool
-	Error   string
+	Success   bool
+	Error     string
+	ErrorType string        // 错误类型：timeout-超时，canceled-已取消，connection-连接错误，temporary-4xx临时错误，permanent-5xx永久错误
+	Attempts  []SendAttempt // 每次投递尝试的记录，故障转移时有多条
+
+	Recipients []RecipientResult // 每个收件人的投递结果（最后一次尝试）
+	Warnings   []string          // 警告，如内容无法用目标字符集表示时回退为 UTF-8
+	MessageID  string            // 邮件的 Message-ID（不含尖括号），故障转移时为最后一次尝试生成的邮件
 }
 
 // GetDefaultConfig 从环境变量获取默认配置
@@ -45,14 +89,37 @@ func GetDefaultConfig() EmailConfig {
 	if port == 0 {
 		port = 587
 	}
-	return EmailConfig{
-		Host:     strings.TrimSpace(os.Getenv("SMTP_HOST")),
-		Port:     port,
-		Username: strings.TrimSpace(os.Getenv("SMTP_USERNAME")),
-		Password: strings.TrimSpace(os.Getenv("SMTP_PASSWORD")),
-		From:     strings.TrimSpace(os.Getenv("SMTP_FROM")),
-		FromName: strings.TrimSpace(os.Getenv("SMTP_FROM_NAME")),
+	skipVerify, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv("SMTP_TLS_SKIP_VERIFY")))
+	config := EmailConfig{
+		Account:       DefaultAccount,
+		Host:          strings.TrimSpace(os.Getenv("SMTP_HOST")),
+		Port:          port,
+		Username:      strings.TrimSpace(os.Getenv("SMTP_USERNAME")),
+		Password:      strings.TrimSpace(os.Getenv("SMTP_PASSWORD")),
+		From:          strings.TrimSpace(os.Getenv("SMTP_FROM")),
+		FromName:      strings.TrimSpace(os.Getenv("SMTP_FROM_NAME")),
+		TLSMode:       strings.TrimSpace(os.Getenv("SMTP_TLS_MODE")),
+		TLSSkipVerify: skipVerify,
+		TLSCAFile:     strings.TrimSpace(os.Getenv("SMTP_TLS_CA_FILE")),
+		TLSMinVersion: strings.TrimSpace(os.Getenv("SMTP_TLS_MIN_VERSION")),
+		TLSCertFile:   strings.TrimSpace(os.Getenv("SMTP_TLS_CERT_FILE")),
+		TLSKeyFile:    strings.TrimSpace(os.Getenv("SMTP_TLS_KEY_FILE")),
+
+		AuthMechanism:      strings.TrimSpace(os.Getenv("SMTP_AUTH")),
+		OAuth2AccessToken:  strings.TrimSpace(os.Getenv("SMTP_OAUTH2_ACCESS_TOKEN")),
+		OAuth2TokenURL:     strings.TrimSpace(os.Getenv("SMTP_OAUTH2_TOKEN_URL")),
+		OAuth2ClientID:     strings.TrimSpace(os.Getenv("SMTP_OAUTH2_CLIENT_ID")),
+		OAuth2ClientSecret: strings.TrimSpace(os.Getenv("SMTP_OAUTH2_CLIENT_SECRET")),
+		OAuth2RefreshToken: strings.TrimSpace(os.Getenv("SMTP_OAUTH2_REFRESH_TOKEN")),
+		OAuth2Scope:        strings.TrimSpace(os.Getenv("SMTP_OAUTH2_SCOPE")),
+	}
+	for _, account := range strings.Split(os.Getenv("SMTP_FAILOVER"), ",") {
+		if account = strings.TrimSpace(account); account != "" {
+			config.Failover = append(config.Failover, account)
+		}
 	}
+	applyEmailSettings(&config)
+	return config
 }
 
 // cleanHeader 彻底清除导致 Header 截断的非法换行符（修复核心）
@@ -60,146 +127,97 @@ func cleanHeader(in string) string {
 	return strings.TrimSpace(strings.ReplaceAll(strings.ReplaceAll(in, "\r", ""), "\n", ""))
 }
 
-// SendEmail 发送邮件
-func SendEmail(config EmailConfig, message EmailMessage) EmailResult {
+// SendEmail 发送邮件，ctx 取消时中断 SMTP 会话
+func SendEmail(ctx context.Context, config EmailConfig, message EmailMessage) EmailResult {
 	if config.Host == "" {
 		return EmailResult{Success: false, Error: "SMTP Host 未配置"}
 	}
 	if len(message.To) == 0 {
 		return EmailResult{Success: false, Error: "收件人不能为空"}
 	}
-
-	var buf bytes.Buffer
-
-	// Go 底层 smtp.Data() 会自动把 \n 转换为规范的 \r\n，手动写 \r\n 遇到特殊环境会变成 \r\r\n 导致信头破裂
-	hostname := "localhost"
-	if parts := strings.Split(config.Host, ":"); len(parts) > 0 && parts[0] != "" {
-		hostname = parts[0]
-	}
-	buf.WriteString(fmt.Sprintf("Message-ID: <%d@%s>\n", time.Now().UnixNano(), hostname))
-	buf.WriteString(fmt.Sprintf("Date: %s\n", time.Now().Format(time.RFC1123Z)))
-
-	fromName := cleanHeader(config.FromName)
-	fromEmail := cleanHeader(config.From)
-	if fromName != "" {
-		buf.WriteString(fmt.Sprintf("From: %s <%s>\n", mime.BEncoding.Encode("UTF-8", fromName), fromEmail))
-	} else {
-		buf.WriteString(fmt.Sprintf("From: %s\n", fromEmail))
-	}
-
-	var cleanTo []string
-	for _, to := range message.To {
-		cleanTo = append(cleanTo, cleanHeader(to))
+	if err := ValidatePriority(message.Priority); err != nil {
+		return EmailResult{Success: false, Error: err.Error()}
 	}
-	buf.WriteString(fmt.Sprintf("To: %s\n", strings.Join(cleanTo, ",")))
-
-	if len(message.Cc) > 0 {
-		var cleanCc []string
-		for _, cc := range message.Cc {
-			cleanCc = append(cleanCc, cleanHeader(cc))
-		}
-		buf.WriteString(fmt.Sprintf("Cc: %s\n", strings.Join(cleanCc, ",")))
+	if err := ValidateHeaders(message.Headers); err != nil {
+		return EmailResult{Success: false, Error: err.Error()}
 	}
-
-	buf.WriteString(fmt.Sprintf("Subject: %s\n", mime.BEncoding.Encode("UTF-8", cleanHeader(message.Subject))))
-	buf.WriteString("MIME-Version: 1.0\n")
-
-	if message.IsHTML {
-		buf.WriteString("Content-Type: text/html; charset=\"UTF-8\"\n")
-	} else {
-		buf.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\n")
+	if _, err := LookupCharset(message.Charset); err != nil {
+		return EmailResult{Success: false, Error: err.Error()}
 	}
-
-	buf.WriteString("Content-Transfer-Encoding: base64\n")
-
-	// 唯一的空行，严格分隔 Header 和 Body
-	buf.WriteString("\n")
-
-	// Body Base64 每 76 字符换行
-	encodedBody := base64.StdEncoding.EncodeToString([]byte(message.Body))
-	for i := 0; i < len(encodedBody); i += 76 {
-		end := i + 76
-		if end > len(encodedBody) {
-			end = len(encodedBody)
+	if message.Calendar != nil {
+		event := *message.Calendar
+		if err := NormalizeCalendarEvent(&event); err != nil {
+			return EmailResult{Success: false, Error: err.Error()}
 		}
-		buf.WriteString(encodedBody[i:end] + "\n")
-	}
-
-	allRecipients := append(cleanTo, message.Cc...)
-	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
-	auth := smtp.PlainAuth("", config.Username, config.Password, config.Host)
-
-	var err error
-	if config.Port == 465 {
-		err = sendMailWithSSL(addr, auth, fromEmail, allRecipients, buf.Bytes())
-	} else {
-		err = smtp.SendMail(addr, auth, fromEmail, allRecipients, buf.Bytes())
+		message.Calendar = &event
 	}
 
+	from, err := NewAddress(config.FromName, config.From)
 	if err != nil {
-		return EmailResult{Success: false, Error: err.Error()}
+		return EmailResult{Success: false, Error: "发件人" + err.Error()}
 	}
 
-	return EmailResult{Success: true, Error: ""}
-}
-
-// sendMailWithSSL 使用 SSL 发送邮件 (端口465)
-func sendMailWithSSL(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
-	host := strings.Split(addr, ":")[0]
-
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: true,
-		ServerName:         host,
-	}
-
-	conn, err := tls.Dial("tcp", addr, tlsConfig)
-	if err != nil {
-		return err
-	}
-	defer conn.Close()
+	// 同一地址只投递一次，密送地址只在 RCPT TO 中使用，不能写入信头
+	to, cc, bcc := DedupeRecipients(message.To, message.Cc, message.Bcc)
+	msg, messageID, warnings := NewMIMEBuilder().BuildMessage(from, to, cc, message)
 
-	client, err := smtp.NewClient(conn, host)
+	// 发件域名配置了 DKIM 时签名，签名后不能再修改邮件内容
+	dkimConfig, err := GetDKIMConfig(from.Address[strings.LastIndex(from.Address, "@")+1:])
 	if err != nil {
-		return err
+		return EmailResult{Success: false, Error: err.Error()}
 	}
-	defer client.Close()
-
-	if auth != nil {
-		if err = client.Auth(auth); err != nil {
-			return err
+	if dkimConfig != nil {
+		if msg, err = SignDKIM(msg, *dkimConfig); err != nil {
+			return EmailResult{Success: false, Error: err.Error()}
 		}
 	}
 
-	if err = client.Mail(from); err != nil {
-		return err
-	}
-
-	for _, addr := range to {
-		if err = client.Rcpt(addr); err != nil {
-			return err
+	var recipients, recipientTypes []string
+	for _, item := range []struct {
+		recipientType string
+		addresses     []mail.Address
+	}{{RecipientTypeTo, to}, {RecipientTypeCc, cc}, {RecipientTypeBcc, bcc}} {
+		for _, address := range item.addresses {
+			recipients = append(recipients, address.Address)
+			recipientTypes = append(recipientTypes, item.recipientType)
 		}
 	}
 
-	w, err := client.Data()
-	if err != nil {
-		return err
+	start := time.Now()
+	results, err := sendMail(ctx, config, from.Address, recipients, msg)
+	for i := range results {
+		results[i].Type = recipientTypes[i]
 	}
-
-	_, err = w.Write(msg)
-	if err != nil {
-		return err
+	attempt := SendAttempt{
+		Account: config.Account,
+		Host:    config.Host,
+		Port:    config.Port,
+		Latency: time.Since(start),
 	}
-
-	err = w.Close()
 	if err != nil {
-		return err
+		attempt.ErrorType = sendErrorType(ctx, err)
+		attempt.Code = replyCode(err)
+		switch attempt.ErrorType {
+		case ErrorTypeCanceled:
+			attempt.Error = "请求已取消，邮件未发送完成"
+		case ErrorTypeTimeout:
+			attempt.Error = "SMTP 服务器响应超时: " + err.Error()
+		default:
+			attempt.Error = err.Error()
+			if CountRecipients(results, RecipientStatusRejected) == len(results) {
+				attempt.Error = "所有收件人均被拒绝: " + err.Error()
+			}
+		}
+		return EmailResult{Success: false, Error: attempt.Error, ErrorType: attempt.ErrorType, Attempts: []SendAttempt{attempt}, Recipients: results, Warnings: warnings, MessageID: messageID}
 	}
 
-	return client.Quit()
+	// 部分收件人被拒绝时邮件仍然发送成功，被拒绝的收件人记录在 Recipients 中
+	attempt.Success = true
+	return EmailResult{Success: true, Error: "", Attempts: []SendAttempt{attempt}, Recipients: results, Warnings: warnings, MessageID: messageID}
 }
 
 // SendEmailWithDefaultConfig 使用默认配置发送邮件
-func SendEmailWithDefaultConfig(message EmailMessage) EmailResult {
+func SendEmailWithDefaultConfig(ctx context.Context, message EmailMessage) EmailResult {
 	config := GetDefaultConfig()
-	return SendEmail(config, message)
+	return SendEmail(ctx, config, message)
 }
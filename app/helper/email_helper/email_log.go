@@ -2,6 +2,7 @@ package email_helper
 
 import (
 	"encoding/json"
+	"fmt"
 	"gin_base/app/helper/db_helper"
 	"gin_base/app/helper/log_helper"
 	"gin_base/app/model"
@@ -10,49 +11,156 @@ import (
 
 // LogEmailRequest 记录邮件请求和结果到数据库（异步）
 func LogEmailRequest(requestIP string, message EmailMessage, config EmailConfig, result EmailResult, requestData interface{}) {
+	// 会话同步记录，同一会话紧接着发送的邮件需要引用这封邮件
+	if result.Success {
+		SaveThreadMessage(message, result.MessageID, 0)
+	}
+
 	// 异步记录，不阻塞主流程
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				log_helper.Error("记录邮件日志panic: %v", r)
+				log_helper.Error(fmt.Sprintf("记录邮件日志panic: %v", r))
 			}
 		}()
 
-		// 将请求参数转为JSON字符串
-		requestDataJSON := ""
-		if requestData != nil {
-			if jsonData, err := json.Marshal(requestData); err == nil {
-				requestDataJSON = string(jsonData)
-			}
+		emailLog := NewEmailLog(requestIP, message, config, result, requestData)
+		SaveEmailLog(&emailLog, result)
+	}()
+}
+
+// NewEmailLog 根据邮件内容和发送结果构建发送记录
+func NewEmailLog(requestIP string, message EmailMessage, config EmailConfig, result EmailResult, requestData interface{}) model.EmailLog {
+	// 将请求参数转为JSON字符串
+	requestDataJSON := ""
+	if requestData != nil {
+		if jsonData, err := json.Marshal(requestData); err == nil {
+			requestDataJSON = string(jsonData)
 		}
+	}
 
-		// 构建邮件记录
-		var isHTML int8 = 0
-		if message.IsHTML {
-			isHTML = 1
+	// 附件和内联资源只记录文件信息，不保存内容
+	attachmentsJSON := ""
+	if len(message.Attachments) > 0 || len(message.Inlines) > 0 {
+		var attachments []map[string]interface{}
+		for _, attachment := range append(message.Attachments, message.Inlines...) {
+			item := map[string]interface{}{
+				"filename":     attachment.Filename,
+				"content_type": attachment.ContentType,
+				"size":         len(attachment.Content),
+			}
+			if attachment.ContentID != "" {
+				item["content_id"] = attachment.ContentID
+			}
+			attachments = append(attachments, item)
 		}
-		var success int8 = 0
-		if result.Success {
-			success = 1
+		if jsonData, err := json.Marshal(attachments); err == nil {
+			attachmentsJSON = string(jsonData)
+		}
+	}
+
+	headersJSON := ""
+	if len(message.Headers) > 0 {
+		if jsonData, err := json.Marshal(message.Headers); err == nil {
+			headersJSON = string(jsonData)
 		}
+	}
 
-		emailLog := model.EmailLog{
-			RequestIP:   requestIP,
-			ToEmail:     strings.Join(message.To, ","),
-			CcEmail:     strings.Join(message.Cc, ","),
-			Subject:     message.Subject,
-			Body:        message.Body,
-			IsHTML:      isHTML,
-			Success:     success,
-			Error:       result.Error,
-			SmtpHost:    config.Host,
-			SmtpPort:    config.Port,
-			RequestData: requestDataJSON,
+	// 实际发送的账号和服务器以最后一次尝试为准（故障转移时为发送成功的备用账号）
+	account, smtpHost, smtpPort := config.Account, config.Host, config.Port
+	if len(result.Attempts) > 0 {
+		account = result.Attempts[len(result.Attempts)-1].Account
+		smtpHost = result.Attempts[len(result.Attempts)-1].Host
+		smtpPort = result.Attempts[len(result.Attempts)-1].Port
+	}
+
+	// 构建邮件记录
+	var isHTML int8 = 0
+	if message.IsHTML {
+		isHTML = 1
+	}
+	var success int8 = 0
+	if result.Success {
+		success = 1
+	}
+
+	return model.EmailLog{
+		Account:     account,
+		RequestIP:   requestIP,
+		ToEmail:     FormatAddressList(message.To),
+		CcEmail:     FormatAddressList(message.Cc),
+		BccEmail:    FormatAddressList(message.Bcc),
+		ReplyTo:     FormatAddressList(message.ReplyTo),
+		Subject:     message.Subject,
+		Body:        message.Body,
+		IsHTML:      isHTML,
+		Priority:    int8(message.Priority),
+		Headers:     headersJSON,
+		Category:    message.Category,
+		ThreadKey:   message.ThreadKey,
+		MessageId:   result.MessageID,
+		InReplyTo:   message.InReplyTo,
+		References:  strings.Join(message.References, " "),
+		Success:     success,
+		Error:       result.Error,
+		ErrorType:   result.ErrorType,
+		SmtpHost:    smtpHost,
+		SmtpPort:    smtpPort,
+		Attachments: attachmentsJSON,
+		RequestData: requestDataJSON,
+	}
+}
+
+// SaveEmailLog 保存发送记录、每次投递尝试和每个收件人的投递结果
+func SaveEmailLog(emailLog *model.EmailLog, result EmailResult) error {
+	// 保存到数据库
+	if err := db_helper.Db().Create(emailLog).Error; err != nil {
+		log_helper.Error(fmt.Sprintf("记录邮件日志失败: %v", err))
+		return err
+	}
+
+	// 保存每次投递尝试
+	if len(result.Attempts) > 0 {
+		var attempts []model.EmailLogAttempt
+		for i, attempt := range result.Attempts {
+			var attemptSuccess int8 = 0
+			if attempt.Success {
+				attemptSuccess = 1
+			}
+			attempts = append(attempts, model.EmailLogAttempt{
+				EmailLogId: emailLog.Id,
+				Sort:       i + 1,
+				Account:    attempt.Account,
+				SmtpHost:   attempt.Host,
+				SmtpPort:   attempt.Port,
+				Success:    attemptSuccess,
+				Error:      attempt.Error,
+				ErrorType:  attempt.ErrorType,
+				Code:       attempt.Code,
+				LatencyMs:  attempt.Latency.Milliseconds(),
+			})
+		}
+		if err := db_helper.Db().Create(&attempts).Error; err != nil {
+			log_helper.Error(fmt.Sprintf("记录邮件投递尝试失败: %v", err))
 		}
+	}
 
-		// 保存到数据库
-		if err := db_helper.Db().Create(&emailLog).Error; err != nil {
-			log_helper.Error("记录邮件日志失败: %v", err)
+	// 保存每个收件人的投递结果
+	if len(result.Recipients) > 0 {
+		var recipients []model.EmailLogRecipient
+		for _, recipient := range result.Recipients {
+			recipients = append(recipients, model.EmailLogRecipient{
+				EmailLogId: emailLog.Id,
+				Email:      recipient.Address,
+				Type:       recipient.Type,
+				Status:     recipient.Status,
+				Code:       recipient.Code,
+				Message:    recipient.Message,
+			})
 		}
-	}()
+		if err := db_helper.Db().Create(&recipients).Error; err != nil {
+			log_helper.Error(fmt.Sprintf("记录邮件收件人投递结果失败: %v", err))
+		}
+	}
+	return nil
 }
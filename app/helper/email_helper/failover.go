@@ -0,0 +1,132 @@
+package email_helper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SendAttempt 一次投递尝试
+type SendAttempt struct {
+	Account   string        // SMTP 账号名称
+	Host      string        // SMTP 服务器
+	Port      int           // SMTP 端口
+	Success   bool          // 是否成功
+	Error     string        // 错误信息
+	ErrorType string        // 错误类型
+	Code      int           // SMTP 服务器响应码，非服务器响应的错误为 0
+	Latency   time.Duration // 耗时
+}
+
+var (
+	// 处于冷却中的 SMTP 服务器及冷却结束时间
+	providerCooldowns   = make(map[string]time.Time)
+	providerCooldownsMu sync.Mutex
+)
+
+// GetFailoverChain 获取账号的故障转移链：账号本身在前，其后是 failover 中配置的备用账号
+// 备用账号自身的 failover 配置不会展开
+func GetFailoverChain(account string) ([]EmailConfig, error) {
+	primary, err := GetAccountConfig(account)
+	if err != nil {
+		return nil, err
+	}
+	chain := []EmailConfig{primary}
+	seen := map[string]bool{primary.Account: true}
+	for _, name := range primary.Failover {
+		config, err := GetAccountConfig(name)
+		if err != nil {
+			return nil, fmt.Errorf("故障转移账号配置错误: %v", err)
+		}
+		if seen[config.Account] {
+			continue
+		}
+		seen[config.Account] = true
+		chain = append(chain, config)
+	}
+	return chain, nil
+}
+
+// SendEmailWithFailover 按顺序使用故障转移链中的账号发送邮件
+// 连接错误、超时和 4xx 临时错误时尝试下一个账号；5xx 永久错误、请求取消或邮件内容错误时直接返回
+// 连接失败的服务器在冷却时间内会被跳过，全部处于冷却中时仍按顺序尝试
+func SendEmailWithFailover(ctx context.Context, configs []EmailConfig, message EmailMessage) EmailResult {
+	var result EmailResult
+	var attempts []SendAttempt
+	for _, config := range availableProviders(configs) {
+		result = SendEmail(ctx, config, message)
+		attempts = append(attempts, result.Attempts...)
+		if result.Success {
+			clearProviderCooldown(config)
+			break
+		}
+		if len(result.Attempts) > 0 && shouldCooldown(result.Attempts[len(result.Attempts)-1]) {
+			setProviderCooldown(config)
+		}
+		if !shouldFailover(result.ErrorType) || ctx.Err() != nil {
+			break
+		}
+	}
+	result.Attempts = attempts
+	return result
+}
+
+// shouldFailover 判断错误是否需要尝试下一个账号
+func shouldFailover(errorType string) bool {
+	switch errorType {
+	case ErrorTypeConnection, ErrorTypeTimeout, ErrorTypeTemporary:
+		return true
+	}
+	return false
+}
+
+// shouldCooldown 判断服务器是否暂时不可用：连接错误、超时，或 421 服务不可用
+func shouldCooldown(attempt SendAttempt) bool {
+	return attempt.ErrorType == ErrorTypeConnection || attempt.ErrorType == ErrorTypeTimeout || attempt.Code == 421
+}
+
+// availableProviders 过滤处于冷却中的账号，全部处于冷却中时返回原列表
+func availableProviders(configs []EmailConfig) []EmailConfig {
+	providerCooldownsMu.Lock()
+	defer providerCooldownsMu.Unlock()
+
+	var available []EmailConfig
+	now := time.Now()
+	for _, config := range configs {
+		key := providerKey(config)
+		if until, ok := providerCooldowns[key]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(providerCooldowns, key)
+		}
+		available = append(available, config)
+	}
+	if len(available) == 0 {
+		return configs
+	}
+	return available
+}
+
+// setProviderCooldown 将服务器标记为冷却中，冷却时间为 0 时不冷却
+func setProviderCooldown(config EmailConfig) {
+	if config.FailoverCooldown <= 0 {
+		return
+	}
+	providerCooldownsMu.Lock()
+	providerCooldowns[providerKey(config)] = time.Now().Add(config.FailoverCooldown)
+	providerCooldownsMu.Unlock()
+}
+
+// clearProviderCooldown 发送成功后清除冷却状态
+func clearProviderCooldown(config EmailConfig) {
+	providerCooldownsMu.Lock()
+	delete(providerCooldowns, providerKey(config))
+	providerCooldownsMu.Unlock()
+}
+
+// providerKey 冷却状态按账号和服务器区分
+func providerKey(config EmailConfig) string {
+	return fmt.Sprintf("%s|%s|%d", config.Account, config.Host, config.Port)
+}
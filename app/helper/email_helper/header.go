@@ -0,0 +1,87 @@
+package email_helper
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 邮件优先级，取值与 X-Priority 一致
+const (
+	PriorityNone   = 0 // 不设置
+	PriorityHigh   = 1 // 高
+	PriorityNormal = 3 // 普通
+	PriorityLow    = 5 // 低
+)
+
+// 自定义信头名称规则：X- 开头，只允许字母、数字和中划线
+var customHeaderRegexp = regexp.MustCompile(`^[Xx]-[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// 由程序生成、不允许自定义覆盖的 X- 信头
+var reservedCustomHeaders = map[string]bool{
+	"X-PRIORITY":         true,
+	"X-MSMAIL-PRIORITY":  true,
+	"X-MAILER":           true,
+	"X-ORIGINATING-IP":   true,
+	"X-ORIGINATING-HOST": true,
+}
+
+// ValidateHeaders 校验自定义信头，只允许 X- 开头的信头
+func ValidateHeaders(headers map[string]string) error {
+	for name, value := range headers {
+		if len(name) > 76 || !customHeaderRegexp.MatchString(name) {
+			return fmt.Errorf("自定义信头名称不合法: %s，只允许 X- 开头的字母、数字和中划线", name)
+		}
+		if reservedCustomHeaders[strings.ToUpper(name)] {
+			return fmt.Errorf("自定义信头 %s 由系统生成，不能自定义", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("自定义信头 %s 的值不能包含换行符", name)
+		}
+		if len(value) > 998 {
+			return fmt.Errorf("自定义信头 %s 的值过长", name)
+		}
+	}
+	return nil
+}
+
+// ValidatePriority 校验优先级
+func ValidatePriority(priority int) error {
+	switch priority {
+	case PriorityNone, PriorityHigh, PriorityNormal, PriorityLow:
+		return nil
+	}
+	return errors.New("优先级只能为 1-高、3-普通、5-低")
+}
+
+// setPriorityHeaders 设置优先级信头，同时兼容 Outlook（Importance/X-MSMail-Priority）和其他客户端（X-Priority）
+func setPriorityHeaders(header *MIMEHeader, priority int) {
+	switch priority {
+	case PriorityHigh:
+		header.Set("X-Priority", "1 (Highest)")
+		header.Set("X-MSMail-Priority", "High")
+		header.Set("Importance", "high")
+	case PriorityNormal:
+		header.Set("X-Priority", "3 (Normal)")
+		header.Set("X-MSMail-Priority", "Normal")
+		header.Set("Importance", "normal")
+	case PriorityLow:
+		header.Set("X-Priority", "5 (Lowest)")
+		header.Set("X-MSMail-Priority", "Low")
+		header.Set("Importance", "low")
+	}
+}
+
+// setCustomHeaders 按名称顺序设置自定义信头，非 ASCII 值使用 RFC 2047 编码
+func setCustomHeaders(header *MIMEHeader, headers map[string]string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header.SetText(name, headers[name])
+	}
+}
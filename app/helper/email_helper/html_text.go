@@ -0,0 +1,219 @@
+package email_helper
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// 连续空白字符
+	htmlSpaceRegexp = regexp.MustCompile(`[ \t\r\n\f]+`)
+	// 行尾空白
+	trailingSpaceRegexp = regexp.MustCompile(`[ \t]+\n`)
+	// 连续三个及以上换行合并为一个空行
+	multiNewlineRegexp = regexp.MustCompile(`\n{3,}`)
+)
+
+// 内容需要整体丢弃的标签
+var htmlSkipTags = map[string]bool{
+	"script": true, "style": true, "head": true, "title": true, "noscript": true, "template": true,
+}
+
+// 前后需要换行的块级标签
+var htmlBlockTags = map[string]bool{
+	"div": true, "section": true, "article": true, "header": true, "footer": true, "nav": true,
+	"aside": true, "main": true, "table": true, "tr": true, "dl": true, "dt": true, "dd": true,
+	"form": true, "fieldset": true, "address": true, "figure": true, "figcaption": true, "center": true,
+}
+
+// 前后需要空一行的段落标签
+var htmlParagraphTags = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"blockquote": true, "pre": true,
+}
+
+// htmlTextWriter HTML 转纯文本的输出缓冲
+type htmlTextWriter struct {
+	buf   bytes.Buffer
+	lists []*htmlTextList // 当前所在的列表（支持嵌套）
+	links []string        // 当前所在的链接地址
+}
+
+// htmlTextList 列表状态，有序列表需要记录序号
+type htmlTextList struct {
+	ordered bool
+	index   int
+}
+
+// HTMLToText 将 HTML 转换为纯文本，用于生成 multipart/alternative 的 text/plain 部分
+// 保留链接地址、列表符号和换行，丢弃 style/script 等不可见内容
+func HTMLToText(s string) string {
+	w := &htmlTextWriter{}
+	skipDepth := 0
+	preDepth := 0
+
+	tokenizer := html.NewTokenizer(strings.NewReader(s))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		tag := token.Data
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if htmlSkipTags[tag] {
+				if tokenType == html.StartTagToken {
+					skipDepth++
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			switch {
+			case tag == "br":
+				w.buf.WriteString("\n")
+			case tag == "hr":
+				w.newline(1)
+				w.buf.WriteString("----------------------------------------")
+				w.newline(1)
+			case tag == "ul" || tag == "ol":
+				w.newline(1)
+				w.lists = append(w.lists, &htmlTextList{ordered: tag == "ol"})
+			case tag == "li":
+				w.writeListItem()
+			case tag == "td" || tag == "th":
+				if !w.atLineStart() {
+					w.buf.WriteString("\t")
+				}
+			case tag == "img":
+				if alt := htmlAttr(token, "alt"); alt != "" {
+					w.buf.WriteString("[" + alt + "]")
+				}
+			case tag == "a":
+				w.links = append(w.links, htmlAttr(token, "href"))
+			case htmlParagraphTags[tag]:
+				w.newline(2)
+				if tag == "pre" {
+					preDepth++
+				}
+			case htmlBlockTags[tag]:
+				w.newline(1)
+			}
+		case html.EndTagToken:
+			if htmlSkipTags[tag] {
+				if skipDepth > 0 {
+					skipDepth--
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			switch {
+			case tag == "ul" || tag == "ol":
+				if len(w.lists) > 0 {
+					w.lists = w.lists[:len(w.lists)-1]
+				}
+				w.newline(1)
+			case tag == "li":
+				w.newline(1)
+			case tag == "a":
+				if len(w.links) > 0 {
+					href := w.links[len(w.links)-1]
+					w.links = w.links[:len(w.links)-1]
+					w.writeLink(href)
+				}
+			case htmlParagraphTags[tag]:
+				w.newline(2)
+				if tag == "pre" && preDepth > 0 {
+					preDepth--
+				}
+			case htmlBlockTags[tag]:
+				w.newline(1)
+			}
+		case html.TextToken:
+			if skipDepth > 0 {
+				continue
+			}
+			text := token.Data
+			if preDepth == 0 {
+				// 非 pre 内容按 HTML 规则合并空白
+				text = htmlSpaceRegexp.ReplaceAllString(text, " ")
+				if w.atLineStart() || bytes.HasSuffix(w.buf.Bytes(), []byte(" ")) {
+					text = strings.TrimLeft(text, " ")
+				}
+			}
+			w.buf.WriteString(text)
+		}
+	}
+
+	text := strings.ReplaceAll(w.buf.String(), " ", " ")
+	text = trailingSpaceRegexp.ReplaceAllString(text, "\n")
+	text = multiNewlineRegexp.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// atLineStart 判断当前是否处于行首
+func (w *htmlTextWriter) atLineStart() bool {
+	return w.buf.Len() == 0 || bytes.HasSuffix(w.buf.Bytes(), []byte("\n"))
+}
+
+// newline 确保输出以至少 n 个换行结尾
+func (w *htmlTextWriter) newline(n int) {
+	if w.buf.Len() == 0 {
+		return
+	}
+	trimmed := bytes.TrimRight(w.buf.Bytes(), " \t")
+	existing := len(trimmed) - len(bytes.TrimRight(trimmed, "\n"))
+	w.buf.Truncate(len(trimmed))
+	for i := existing; i < n; i++ {
+		w.buf.WriteString("\n")
+	}
+}
+
+// writeListItem 写入列表项符号，有序列表写序号，嵌套列表缩进
+func (w *htmlTextWriter) writeListItem() {
+	w.newline(1)
+	indent := ""
+	if len(w.lists) > 1 {
+		indent = strings.Repeat("  ", len(w.lists)-1)
+	}
+	if len(w.lists) > 0 && w.lists[len(w.lists)-1].ordered {
+		list := w.lists[len(w.lists)-1]
+		list.index++
+		w.buf.WriteString(fmt.Sprintf("%s%d. ", indent, list.index))
+	} else {
+		w.buf.WriteString(indent + "* ")
+	}
+}
+
+// writeLink 在链接文字后追加链接地址，地址与文字相同或为锚点、cid 等时不追加
+func (w *htmlTextWriter) writeLink(href string) {
+	href = strings.TrimSpace(href)
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(lower, "#") || strings.HasPrefix(lower, "javascript:") || strings.HasPrefix(lower, "cid:") {
+		return
+	}
+	current := bytes.TrimRight(w.buf.Bytes(), " ")
+	if bytes.HasSuffix(current, []byte(href)) || bytes.HasSuffix(current, []byte(strings.TrimPrefix(href, "mailto:"))) {
+		return
+	}
+	w.buf.Truncate(len(current))
+	w.buf.WriteString(" (" + href + ")")
+}
+
+// htmlAttr 获取标签属性
+func htmlAttr(token html.Token, name string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
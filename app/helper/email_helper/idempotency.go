@@ -0,0 +1,227 @@
+package email_helper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin_base/app/helper/cache_helper"
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/helper/exception_helper"
+	"gin_base/app/helper/helper"
+	"gin_base/app/helper/log_helper"
+	"gin_base/app/helper/type_helper"
+	"gin_base/app/model"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 幂等键最大长度
+	maxIdempotencyKeyLength = 255
+	// 默认保存时间
+	defaultIdempotencyWindow = 24 * time.Hour
+	// 处理中的请求超过该时间未完成视为进程已退出，相同幂等键的请求可以重新处理
+	idempotencyLockTimeout = 10 * time.Minute
+	// 相同幂等键的并发请求最多等待的时间
+	idempotencyWaitTimeout = 30 * time.Second
+	// Redis 可用性检测结果的缓存时间
+	idempotencyRedisCheckInterval = time.Minute
+	// Redis 中的键前缀
+	idempotencyRedisPrefix = "EmailIdempotency:"
+)
+
+// Redis 可用性检测结果
+var (
+	idempotencyRedisMutex     sync.Mutex
+	idempotencyRedisOK        bool
+	idempotencyRedisCheckedAt time.Time
+)
+
+// idempotencyRecord 保存的幂等记录
+type idempotencyRecord struct {
+	Fingerprint string                 `json:"fingerprint"`
+	Response    map[string]interface{} `json:"response"`
+}
+
+// IdempotencyClaim 幂等键的处理权，Response 不为 nil 时为之前保存的响应，直接返回即可
+// 否则由当前请求处理，成功后调用 Save 保存响应，最后调用 Release 释放
+type IdempotencyClaim struct {
+	Response map[string]interface{}
+
+	key         string
+	fingerprint string
+	redis       bool
+	lockId      string
+	rowId       uint
+	saved       bool
+}
+
+// ValidateIdempotencyKey 校验幂等键
+func ValidateIdempotencyKey(key string) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("幂等键最长 %d 个字符", maxIdempotencyKeyLength)
+	}
+	if !utf8.ValidString(key) {
+		return errors.New("幂等键包含无效字符")
+	}
+	for _, r := range key {
+		if r < 0x20 || r == 0x7f {
+			return errors.New("幂等键不能包含控制字符")
+		}
+	}
+	return nil
+}
+
+// IdempotencyWindow 幂等键的保存时间，读取 app/appconfig/email.yaml
+func IdempotencyWindow() time.Duration {
+	if window := helper.GetAppConfig().Email.Idempotency_Window; window > 0 {
+		return time.Duration(window) * time.Second
+	}
+	return defaultIdempotencyWindow
+}
+
+// ClaimIdempotencyKey 获取幂等键的处理权，Redis 可用时保存在 Redis 并用 RedisLock 串行处理，否则保存在数据库
+// 相同幂等键的请求正在处理时等待其完成，fingerprint 为请求参数摘要，与之前的请求不同时返回错误
+func ClaimIdempotencyKey(key string, fingerprint string) (*IdempotencyClaim, error) {
+	claim := &IdempotencyClaim{key: key, fingerprint: fingerprint}
+	if idempotencyRedisAvailable() {
+		claim.redis = true
+		return claim, claim.claimRedis()
+	}
+	return claim, claim.claimDB()
+}
+
+// Save 保存响应，保存时间内相同幂等键的请求直接返回该响应
+func (claim *IdempotencyClaim) Save(response map[string]interface{}) {
+	data, err := json.Marshal(idempotencyRecord{Fingerprint: claim.fingerprint, Response: response})
+	if err != nil {
+		log_helper.Error(fmt.Sprintf("保存幂等响应失败: %v", err))
+		return
+	}
+	if claim.redis {
+		err = cache_helper.RedisHelper().RedisSet(idempotencyRedisPrefix+claim.key, string(data), IdempotencyWindow())
+	} else {
+		err = db_helper.Db().Model(&model.EmailIdempotency{}).Where("id = ?", claim.rowId).
+			Updates(map[string]interface{}{"response": string(data), "locked_until": nil}).Error
+	}
+	if err != nil {
+		log_helper.Error(fmt.Sprintf("保存幂等响应失败: %v", err))
+		return
+	}
+	claim.saved = true
+}
+
+// Release 释放处理权，未保存响应时（如系统异常）相同幂等键的请求可以重新处理
+func (claim *IdempotencyClaim) Release() {
+	if claim.Response != nil {
+		return
+	}
+	if claim.redis {
+		if claim.lockId != "" {
+			cache_helper.RedisHelper().RedisUnLock(idempotencyRedisPrefix+claim.key, claim.lockId)
+		}
+		return
+	}
+	if claim.rowId > 0 && !claim.saved {
+		db_helper.Db().Where("id = ? AND response = ''", claim.rowId).Delete(&model.EmailIdempotency{})
+	}
+}
+
+// claimRedis 获取 Redis 锁后检查是否已有保存的响应
+func (claim *IdempotencyClaim) claimRedis() error {
+	redisHelper := cache_helper.RedisHelper()
+	claim.lockId = redisHelper.RedisWaitLockOrException(idempotencyRedisPrefix+claim.key, idempotencyLockTimeout, idempotencyWaitTimeout)
+	data, err := redisHelper.RedisGet(idempotencyRedisPrefix + claim.key)
+	if err != nil || data == "" {
+		return nil
+	}
+	defer func() {
+		if claim.Response != nil {
+			redisHelper.RedisUnLock(idempotencyRedisPrefix+claim.key, claim.lockId)
+		}
+	}()
+	return claim.load(data)
+}
+
+// claimDB 插入处理中的记录，已存在时等待其完成或超时后接管
+func (claim *IdempotencyClaim) claimDB() error {
+	deadline := time.Now().Add(idempotencyWaitTimeout)
+	for {
+		now := time.Now()
+		db_helper.Db().Where("idempotency_key = ? AND expires_at <= ?", claim.key, now).Delete(&model.EmailIdempotency{})
+
+		lockedUntil := type_helper.Time(now.Add(idempotencyLockTimeout))
+		row := model.EmailIdempotency{
+			IdempotencyKey: claim.key,
+			Fingerprint:    claim.fingerprint,
+			LockedUntil:    &lockedUntil,
+			ExpiresAt:      type_helper.Time(now.Add(IdempotencyWindow())),
+		}
+		if err := db_helper.Db().Create(&row).Error; err == nil {
+			claim.rowId = row.Id
+			return nil
+		}
+
+		var existing model.EmailIdempotency
+		if err := db_helper.Db().Where("idempotency_key = ?", claim.key).First(&existing).Error; err == nil {
+			if existing.Response != "" {
+				return claim.load(existing.Response)
+			}
+			// 处理超时（进程已退出），接管该记录
+			if existing.LockedUntil == nil || time.Time(*existing.LockedUntil).Before(now) {
+				taken := db_helper.Db().Model(&model.EmailIdempotency{}).
+					Where("id = ? AND response = '' AND (locked_until IS NULL OR locked_until < ?)", existing.Id, now).
+					Updates(map[string]interface{}{"fingerprint": claim.fingerprint, "locked_until": lockedUntil})
+				if taken.Error == nil && taken.RowsAffected > 0 {
+					claim.rowId = existing.Id
+					return nil
+				}
+			}
+		}
+
+		if time.Now().After(deadline) {
+			exception_helper.CommonException("上一个请求正在处理中，请稍后")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// load 解析保存的响应，请求参数与第一次请求不同时返回错误
+func (claim *IdempotencyClaim) load(data string) error {
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil || record.Response == nil {
+		return fmt.Errorf("幂等记录解析失败: %v", err)
+	}
+	if record.Fingerprint != claim.fingerprint {
+		return errors.New("幂等键已用于参数不同的请求")
+	}
+	claim.Response = record.Response
+	return nil
+}
+
+// ClearExpiredIdempotencyKeys 删除数据库中过期的幂等键，由定时任务调用
+func ClearExpiredIdempotencyKeys() {
+	if err := db_helper.Db().Where("expires_at <= ?", time.Now()).Delete(&model.EmailIdempotency{}).Error; err != nil {
+		log_helper.Error(fmt.Sprintf("清理过期幂等键失败: %v", err))
+	}
+}
+
+// idempotencyRedisAvailable Redis 是否可用，检测结果缓存一分钟
+func idempotencyRedisAvailable() bool {
+	idempotencyRedisMutex.Lock()
+	defer idempotencyRedisMutex.Unlock()
+	if time.Since(idempotencyRedisCheckedAt) < idempotencyRedisCheckInterval {
+		return idempotencyRedisOK
+	}
+	if _, ok := helper.GetAppConfig().Redis["default"]; !ok {
+		idempotencyRedisOK = false
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		idempotencyRedisOK = cache_helper.RedisHelper().Client.Ping(ctx).Err() == nil
+	}
+	idempotencyRedisCheckedAt = time.Now()
+	return idempotencyRedisOK
+}
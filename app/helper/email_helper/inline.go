@@ -0,0 +1,70 @@
+package email_helper
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// HTML 中 src="data:image/png;base64,..." 形式的内嵌图片
+var dataURIImageRegexp = regexp.MustCompile(`(?i)(src\s*=\s*)(["'])data:(image/[a-z0-9.+-]+);base64,([a-z0-9+/=\s]+)(["'])`)
+
+// NewInline 创建内联资源，cid 为空时使用去掉扩展名的文件名
+func NewInline(cid string, filename string, content []byte, contentType string) Attachment {
+	attachment := NewAttachment(filename, content, contentType)
+	cid = NormalizeContentID(cid)
+	if cid == "" {
+		cid = NormalizeContentID(strings.TrimSuffix(attachment.Filename, filepath.Ext(attachment.Filename)))
+	}
+	attachment.ContentID = cid
+	return attachment
+}
+
+// NormalizeContentID 规范化 Content-ID，去掉 cid: 前缀、尖括号以及非法字符
+func NormalizeContentID(cid string) string {
+	cid = strings.TrimSpace(cleanHeader(cid))
+	if len(cid) >= 4 && strings.EqualFold(cid[:4], "cid:") {
+		cid = cid[4:]
+	}
+	cid = strings.Trim(cid, "<>")
+	return strings.Map(func(r rune) rune {
+		if r <= 0x20 || r >= 0x7f || strings.ContainsRune(`<>()[]\,;:"`, r) {
+			return -1
+		}
+		return r
+	}, cid)
+}
+
+// EmbedDataURIImages 将 HTML 中的 data: URI 图片转换为 cid 引用，返回新的 HTML 和对应的内联资源
+// 很多邮箱客户端会屏蔽 data: URI 图片，转换为 multipart/related 内联资源后可正常显示
+func EmbedDataURIImages(html string) (string, []Attachment) {
+	var inlines []Attachment
+	html = dataURIImageRegexp.ReplaceAllStringFunc(html, func(match string) string {
+		parts := dataURIImageRegexp.FindStringSubmatch(match)
+		if parts[2] != parts[5] {
+			return match
+		}
+		data := strings.Join(strings.Fields(parts[4]), "")
+		content, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return match
+		}
+		contentType := strings.ToLower(parts[3])
+		cid := fmt.Sprintf("inline%d.%s", len(inlines)+1, randomHex(nil, 8))
+		filename := fmt.Sprintf("image%d", len(inlines)+1)
+		if exts, _ := mime.ExtensionsByType(contentType); len(exts) > 0 {
+			filename += exts[0]
+		}
+		inlines = append(inlines, Attachment{
+			Filename:    filename,
+			ContentType: contentType,
+			Content:     content,
+			ContentID:   cid,
+		})
+		return parts[1] + parts[2] + "cid:" + cid + parts[5]
+	})
+	return html, inlines
+}
@@ -0,0 +1,92 @@
+package email_helper
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+)
+
+// 主题中的换行会被当作信头结束，渲染后替换为空格
+var subjectNewlineReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// MergeTemplate 个性化发送（邮件合并）的模板，主题和纯文本正文使用 text/template，HTML 正文使用 html/template（变量自动转义）
+// 模板中通过 {{.name}} 引用收件人的变量，引用了收件人没有的变量时渲染失败
+type MergeTemplate struct {
+	subject  *texttemplate.Template
+	body     merger
+	textBody *texttemplate.Template
+}
+
+// MergeResult 渲染后的邮件内容
+type MergeResult struct {
+	Subject  string
+	Body     string
+	TextBody string
+}
+
+// merger text/template 和 html/template 共同的渲染方法
+type merger interface {
+	Execute(wr io.Writer, data any) error
+}
+
+// ParseMergeTemplate 解析主题、正文和纯文本正文模板，textBody 为空时不生成纯文本正文模板
+func ParseMergeTemplate(subject string, body string, textBody string, isHTML bool) (*MergeTemplate, error) {
+	t := &MergeTemplate{}
+	var err error
+	if t.subject, err = texttemplate.New("subject").Option("missingkey=error").Parse(subject); err != nil {
+		return nil, fmt.Errorf("主题模板解析失败: %v", err)
+	}
+	if isHTML {
+		t.body, err = htmltemplate.New("body").Option("missingkey=error").Parse(body)
+	} else {
+		t.body, err = texttemplate.New("body").Option("missingkey=error").Parse(body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("正文模板解析失败: %v", err)
+	}
+	if textBody != "" {
+		if t.textBody, err = texttemplate.New("text_body").Option("missingkey=error").Parse(textBody); err != nil {
+			return nil, fmt.Errorf("纯文本正文模板解析失败: %v", err)
+		}
+	}
+	return t, nil
+}
+
+// Render 使用收件人的变量渲染邮件内容，可以并发调用
+func (t *MergeTemplate) Render(variables map[string]interface{}) (MergeResult, error) {
+	if variables == nil {
+		variables = map[string]interface{}{}
+	}
+	var result MergeResult
+	var buf bytes.Buffer
+	if err := t.subject.Execute(&buf, variables); err != nil {
+		return result, fmt.Errorf("主题渲染失败: %v", err)
+	}
+	result.Subject = strings.TrimSpace(subjectNewlineReplacer.Replace(buf.String()))
+	if result.Subject == "" {
+		return result, fmt.Errorf("渲染后的主题为空")
+	}
+
+	buf.Reset()
+	if err := t.body.Execute(&buf, variables); err != nil {
+		return result, fmt.Errorf("正文渲染失败: %v", err)
+	}
+	result.Body = buf.String()
+
+	if t.textBody != nil {
+		buf.Reset()
+		if err := t.textBody.Execute(&buf, variables); err != nil {
+			return result, fmt.Errorf("纯文本正文渲染失败: %v", err)
+		}
+		result.TextBody = buf.String()
+	}
+	return result, nil
+}
+
+// NewBatchId 生成个性化批量发送ID，同一批次的发送记录使用相同的ID
+func NewBatchId() string {
+	return randomHex(nil, 16)
+}
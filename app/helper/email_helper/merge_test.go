@@ -0,0 +1,33 @@
+package email_helper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeTemplateRender(t *testing.T) {
+	tmpl, err := ParseMergeTemplate("{{.name}}，您的账单", "<p>金额 {{.amount}}</p>", "金额 {{.amount}}", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tmpl.Render(map[string]interface{}{"name": "张三\r\nBcc: x@y.com", "amount": "<b>100</b>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Subject != "张三 Bcc: x@y.com，您的账单" {
+		t.Errorf("subject = %q", result.Subject)
+	}
+	if result.Body != "<p>金额 &lt;b&gt;100&lt;/b&gt;</p>" {
+		t.Errorf("body = %q", result.Body)
+	}
+	if result.TextBody != "金额 <b>100</b>" {
+		t.Errorf("text body = %q", result.TextBody)
+	}
+
+	if _, err := tmpl.Render(map[string]interface{}{"name": "李四"}); err == nil || !strings.Contains(err.Error(), "amount") {
+		t.Errorf("missing variable err = %v", err)
+	}
+	if _, err := ParseMergeTemplate("{{.name", "body", "", false); err == nil {
+		t.Error("expected parse error")
+	}
+}
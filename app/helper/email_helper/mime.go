@@ -0,0 +1,544 @@
+package email_helper
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 内容传输编码
+const (
+	Encoding7Bit            = "7bit"
+	EncodingQuotedPrintable = "quoted-printable"
+	EncodingBase64          = "base64"
+)
+
+const (
+	// 信头每行的最大长度，RFC 2047 要求包含编码字的行不超过 76 个字符
+	headerLineLength = 76
+	// 每个编码字的最大长度，如 =?UTF-8?B?...?= 最多 64 个字符，加上信头名称也不会超过 76
+	encodedWordLength = 64
+	// RFC 2231 参数值每段的最小长度，参数名称过长时允许超出行长度
+	minParamSectionLength = 12
+	// 7bit 编码允许的最大行长度，超过时使用 quoted-printable
+	bodyLineLength = 76
+)
+
+// MIMEParam 信头参数，如 Content-Type 的 charset、Content-Disposition 的 filename
+type MIMEParam struct {
+	Name  string
+	Value string
+}
+
+// MIMEHeader 有序的信头列表，写入时自动编码非 ASCII 内容并折行
+type MIMEHeader struct {
+	Charset *Charset // 文本信头和显示名使用的字符集，为空时使用 UTF-8
+
+	fields []mimeField
+}
+
+// mimeField 单个信头，tokens 之间以空格分隔，折行只发生在 token 之间
+type mimeField struct {
+	name   string
+	tokens []string
+}
+
+// Set 设置结构化信头（如 Date、Message-ID），值为 ASCII，在空格处折行
+func (h *MIMEHeader) Set(name string, value string) {
+	h.set(name, strings.Split(cleanHeader(value), " "))
+}
+
+// SetText 设置文本信头（如 Subject），包含非 ASCII 字符时使用 RFC 2047 编码
+func (h *MIMEHeader) SetText(name string, value string) {
+	value = cleanHeader(value)
+	if isASCII(value) && !strings.Contains(value, "=?") {
+		h.set(name, strings.Split(value, " "))
+		return
+	}
+	h.set(name, encodeWords(value, h.Charset))
+}
+
+// SetAddressList 设置地址信头（From、To、Cc、Reply-To），非 ASCII 显示名使用 RFC 2047 编码
+func (h *MIMEHeader) SetAddressList(name string, addresses []mail.Address) {
+	var tokens []string
+	for i, address := range addresses {
+		if address.Name != "" {
+			if !isASCII(address.Name) {
+				tokens = append(tokens, encodeWords(address.Name, h.Charset)...)
+			} else if phrase := quotePhrase(address.Name); phrase != address.Name {
+				tokens = append(tokens, phrase)
+			} else {
+				tokens = append(tokens, strings.Split(phrase, " ")...)
+			}
+		}
+		token := address.Address
+		if address.Name != "" {
+			token = "<" + token + ">"
+		}
+		if i < len(addresses)-1 {
+			token += ","
+		}
+		tokens = append(tokens, token)
+	}
+	h.set(name, tokens)
+}
+
+// SetWithParams 设置带参数的信头（如 Content-Type、Content-Disposition）
+// 非 ASCII 参数值同时写入 RFC 2231 编码（name*）和 RFC 2047 编码（name），兼容新旧客户端
+func (h *MIMEHeader) SetWithParams(name string, value string, params ...MIMEParam) {
+	var segments [][]string
+	for _, param := range params {
+		segments = append(segments, formatParam(param.Name, cleanHeader(param.Value))...)
+	}
+	tokens := []string{cleanHeader(value)}
+	for _, segment := range segments {
+		// 参数之间以分号分隔，同一参数内的编码字之间只用空格
+		tokens[len(tokens)-1] += ";"
+		tokens = append(tokens, segment...)
+	}
+	h.set(name, tokens)
+}
+
+// Get 获取信头的值（未折行）
+func (h *MIMEHeader) Get(name string) string {
+	for _, field := range h.fields {
+		if strings.EqualFold(field.name, name) {
+			return strings.Join(field.tokens, " ")
+		}
+	}
+	return ""
+}
+
+// set 设置信头，已存在时替换
+func (h *MIMEHeader) set(name string, tokens []string) {
+	for i := range h.fields {
+		if strings.EqualFold(h.fields[i].name, name) {
+			h.fields[i].tokens = tokens
+			return
+		}
+	}
+	h.fields = append(h.fields, mimeField{name: name, tokens: tokens})
+}
+
+// Write 写入所有信头，超过行长度时在 token 之间折行
+func (h *MIMEHeader) Write(buf *bytes.Buffer) {
+	for _, field := range h.fields {
+		buf.WriteString(field.name + ":")
+		lineLen := len(field.name) + 1
+		for _, token := range field.tokens {
+			// 不在空 token 前折行，避免出现只有空白的行
+			if token != "" && lineLen > len(field.name)+1 && lineLen+1+len(token) > headerLineLength {
+				buf.WriteString("\n")
+				lineLen = 0
+			}
+			buf.WriteString(" " + token)
+			lineLen += 1 + len(token)
+		}
+		buf.WriteString("\n")
+	}
+}
+
+// encodeWords 将文本编码为多个 RFC 2047 编码字，每个编码字不拆分字符
+// 文本无法用 charset 表示时使用 UTF-8；每个编码字单独转换，ISO-2022-JP 等有状态的编码在编码字结尾切换回 ASCII
+func encodeWords(s string, charset *Charset) []string {
+	if charset.IsUTF8() || !charset.CanEncode(s) {
+		charset = CharsetUTF8
+	}
+	prefix := "=?" + charset.Name + "?B?"
+	// 每个编码字原文的最大字节数，如 UTF-8 为 39 字节
+	maxBytes := (encodedWordLength - len(prefix) - 2) / 4 * 3
+
+	var words []string
+	for len(s) > 0 {
+		end := 0
+		for end < len(s) {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			if data, _ := charset.Encode(s[:end+size]); end > 0 && len(data) > maxBytes {
+				break
+			}
+			end += size
+		}
+		data, _ := charset.Encode(s[:end])
+		words = append(words, prefix+base64.StdEncoding.EncodeToString(data)+"?=")
+		s = s[end:]
+	}
+	if len(words) == 0 {
+		return []string{""}
+	}
+	return words
+}
+
+// formatParam 格式化参数，返回一个或多个参数，每个参数为可折行的 token 列表
+func formatParam(name string, value string) [][]string {
+	if isASCII(value) {
+		if isToken(value) {
+			return [][]string{{name + "=" + value}}
+		}
+		return [][]string{{name + "=" + quoteParam(value)}}
+	}
+
+	// RFC 2047 编码字放在引号内，编码字之间可以折行
+	words := encodeWords(value, nil)
+	words[0] = name + `="` + words[0]
+	words[len(words)-1] += `"`
+	segments := [][]string{words}
+
+	// RFC 2231 编码，过长时拆分为 name*0*、name*1* ...，不拆分 %XX
+	// 每段加上开头的空格和结尾的分号不超过行长度
+	encoded := encodeRFC2231(value)
+	if prefix := name + "*=UTF-8''"; len(prefix)+len(encoded)+2 <= headerLineLength {
+		return append(segments, []string{prefix + encoded})
+	}
+	for section := 0; len(encoded) > 0; section++ {
+		prefix := name + "*" + strconv.Itoa(section) + "*="
+		if section == 0 {
+			prefix += "UTF-8''"
+		}
+		end := headerLineLength - len(prefix) - 2
+		if end < minParamSectionLength {
+			end = minParamSectionLength
+		}
+		if end >= len(encoded) {
+			end = len(encoded)
+		} else if i := strings.LastIndexByte(encoded[end-2:end], '%'); i >= 0 {
+			end = end - 2 + i
+		}
+		segments = append(segments, []string{prefix + encoded[:end]})
+		encoded = encoded[end:]
+	}
+	return segments
+}
+
+// isToken 判断参数值是否可以不加引号（RFC 2045 token）
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	return !strings.ContainsAny(s, " ()<>@,;:\\\"/[]?=")
+}
+
+// MIMEPart MIME 邮件的一个部分，Parts 不为空时为 multipart
+type MIMEPart struct {
+	Header   MIMEHeader
+	Body     []byte      // 未编码的内容
+	Encoding string      // 内容传输编码，为空时根据内容自动选择
+	Parts    []*MIMEPart // multipart 的子部分
+	Preamble string      // multipart 第一个分隔符之前的说明文字
+
+	boundary string
+}
+
+// NewTextPart 创建文本部分，subtype 为 plain 或 html
+// charset 为空或内容无法用 charset 表示时使用 UTF-8
+func NewTextPart(subtype string, body string, charset *Charset) *MIMEPart {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	data, err := charset.Encode(body)
+	if err != nil || charset.IsUTF8() {
+		data, charset = []byte(body), CharsetUTF8
+	}
+	part := &MIMEPart{Body: data}
+	part.Header.SetWithParams("Content-Type", "text/"+subtype, MIMEParam{Name: "charset", Value: charset.Name})
+	return part
+}
+
+// NewAttachmentPart 创建附件部分，带 ContentID 时作为内联资源
+func NewAttachmentPart(attachment Attachment) *MIMEPart {
+	filename := attachment.Filename
+	if filename == "" {
+		filename = "attachment"
+	}
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = DetectContentType(filename, attachment.Content)
+	}
+	disposition := "attachment"
+	if attachment.ContentID != "" {
+		disposition = "inline"
+	}
+
+	part := &MIMEPart{Body: attachment.Content, Encoding: EncodingBase64}
+	part.Header.SetWithParams("Content-Type", contentType, MIMEParam{Name: "name", Value: filename})
+	part.Header.SetWithParams("Content-Disposition", disposition, MIMEParam{Name: "filename", Value: filename})
+	if attachment.ContentID != "" {
+		part.Header.Set("Content-ID", "<"+attachment.ContentID+">")
+	}
+	return part
+}
+
+// Write 写入该部分的信头和内容
+func (p *MIMEPart) Write(buf *bytes.Buffer) {
+	if len(p.Parts) > 0 {
+		p.Header.Write(buf)
+		buf.WriteString("\n")
+		if p.Preamble != "" {
+			buf.WriteString(p.Preamble + "\n")
+		}
+		for _, part := range p.Parts {
+			buf.WriteString("--" + p.boundary + "\n")
+			part.Write(buf)
+		}
+		buf.WriteString("--" + p.boundary + "--\n")
+		return
+	}
+
+	encoding := p.Encoding
+	if encoding == "" {
+		encoding = ChooseTransferEncoding(p.Body)
+	}
+	p.Header.Set("Content-Transfer-Encoding", encoding)
+	p.Header.Write(buf)
+	// 唯一的空行，严格分隔 Header 和 Body
+	buf.WriteString("\n")
+
+	switch encoding {
+	case EncodingBase64:
+		writeBase64(buf, p.Body)
+	case EncodingQuotedPrintable:
+		var encoded bytes.Buffer
+		w := quotedprintable.NewWriter(&encoded)
+		w.Write(p.Body)
+		w.Close()
+		writeLines(buf, strings.ReplaceAll(encoded.String(), "\r\n", "\n"))
+	default:
+		writeLines(buf, string(p.Body))
+	}
+}
+
+// ChooseTransferEncoding 根据内容选择传输编码：短行纯 ASCII 使用 7bit，
+// 需要转义的字节较少时使用 quoted-printable（可读性好），否则使用 base64（如中文正文）
+func ChooseTransferEncoding(body []byte) string {
+	escaped := 0
+	ascii := true
+	lineLen := 0
+	for _, c := range body {
+		if c == '\n' {
+			lineLen = 0
+			continue
+		}
+		lineLen++
+		// ISO-2022-JP 使用 ESC 切换字符集，按惯例以 7bit 发送
+		if c >= 0x80 || (c < 0x20 && c != '\t' && c != 0x1b) || c == 0x7f {
+			escaped++
+			ascii = false
+		} else if c == '=' {
+			escaped++
+		}
+		if lineLen > bodyLineLength {
+			ascii = false
+		}
+	}
+	if ascii {
+		return Encoding7Bit
+	}
+	// quoted-printable 每个转义字节占 3 个字符，base64 整体膨胀 4/3
+	if escaped*6 <= len(body) {
+		return EncodingQuotedPrintable
+	}
+	return EncodingBase64
+}
+
+// writeLines 写入文本内容，末尾追加的换行属于后面的分隔符，不改变内容
+func writeLines(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+	buf.WriteString("\n")
+}
+
+// writeBase64 写入 base64 内容，每 76 字符换行
+func writeBase64(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for i := 0; i < len(encoded); i += 76 {
+		end := i + 76
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+		buf.WriteString(encoded[i:end] + "\n")
+	}
+}
+
+// MIMEBuilder 邮件构建器，Now 和 Rand 为空时使用当前时间和 crypto/rand，测试时可替换以生成固定内容
+type MIMEBuilder struct {
+	Now  func() time.Time
+	Rand io.Reader
+}
+
+// NewMIMEBuilder 创建邮件构建器
+func NewMIMEBuilder() *MIMEBuilder {
+	return &MIMEBuilder{}
+}
+
+// NewMultipart 创建 multipart 部分，如 mixed、alternative、related
+func (b *MIMEBuilder) NewMultipart(subtype string, params []MIMEParam, parts ...*MIMEPart) *MIMEPart {
+	part := &MIMEPart{Parts: parts, boundary: "----=_Part_" + randomHex(b.Rand, 16)}
+	params = append(params, MIMEParam{Name: "boundary", Value: part.boundary})
+	part.Header.SetWithParams("Content-Type", "multipart/"+subtype, params...)
+	return part
+}
+
+// NewMessageID 生成发件人域名下全局唯一的 Message-ID（不含尖括号）：时间戳 + 128 位随机数
+func (b *MIMEBuilder) NewMessageID(from string) string {
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		domain = strings.ToLower(from[i+1:])
+	}
+	return strconv.FormatInt(b.now().UnixNano(), 36) + "." + randomHex(b.Rand, 16) + "@" + domain
+}
+
+// BuildMessage 生成完整邮件，返回邮件内容、Message-ID（不含尖括号）和警告
+// message.Charset 指定目标字符集时，主题、显示名和正文使用该字符集；内容无法表示时回退为 UTF-8 并返回警告
+// 结构为 mixed(alternative(text, related(html, inlines), calendar), attachments)，只有需要时才使用对应的 multipart
+// 统一使用 \n 换行，smtp.Data() 会自动转换为规范的 \r\n，手动写 \r\n 遇到特殊环境会变成 \r\r\n 导致信头破裂
+func (b *MIMEBuilder) BuildMessage(from mail.Address, to []mail.Address, cc []mail.Address, message EmailMessage) ([]byte, string, []string) {
+	messageID := message.MessageID
+	if messageID == "" {
+		messageID = b.NewMessageID(from.Address)
+	}
+	if message.IsHTML && message.TextBody == "" {
+		message.TextBody = HTMLToText(message.Body)
+	}
+
+	var warnings []string
+	charset, err := LookupCharset(message.Charset)
+	if err != nil {
+		charset = CharsetUTF8
+		warnings = append(warnings, err.Error()+"，已使用 UTF-8")
+	}
+	headerCharset, bodyCharset := charset, charset
+	if !charset.IsUTF8() {
+		texts := []string{message.Subject, from.Name}
+		for _, list := range [][]mail.Address{to, cc, message.ReplyTo} {
+			for _, address := range list {
+				texts = append(texts, address.Name)
+			}
+		}
+		for _, value := range message.Headers {
+			texts = append(texts, value)
+		}
+		if !charset.CanEncode(texts...) {
+			headerCharset = CharsetUTF8
+			warnings = append(warnings, fmt.Sprintf("邮件主题或显示名包含 %s 无法表示的字符，已使用 UTF-8", charset.Name))
+		}
+		if !charset.CanEncode(message.Body, message.TextBody) {
+			bodyCharset = CharsetUTF8
+			warnings = append(warnings, fmt.Sprintf("邮件正文包含 %s 无法表示的字符，已使用 UTF-8", charset.Name))
+		}
+	}
+
+	header := MIMEHeader{Charset: headerCharset}
+	header.Set("Message-ID", "<"+messageID+">")
+	header.Set("Date", b.now().Format(time.RFC1123Z))
+	header.SetAddressList("From", []mail.Address{from})
+	header.SetAddressList("To", to)
+	if len(cc) > 0 {
+		header.SetAddressList("Cc", cc)
+	}
+	if len(message.ReplyTo) > 0 {
+		header.SetAddressList("Reply-To", message.ReplyTo)
+	}
+	if message.InReplyTo != "" {
+		header.Set("In-Reply-To", "<"+message.InReplyTo+">")
+	}
+	if len(message.References) > 0 {
+		header.Set("References", "<"+strings.Join(message.References, "> <")+">")
+	}
+	header.SetText("Subject", message.Subject)
+	setPriorityHeaders(&header, message.Priority)
+	if message.UnsubscribeURL != "" {
+		header.Set("List-Unsubscribe", "<"+message.UnsubscribeURL+">")
+		header.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	setCustomHeaders(&header, message.Headers)
+	header.Set("MIME-Version", "1.0")
+
+	// 日历邀请同时作为 text/calendar 正文和 .ics 附件，兼容只识别其中一种的客户端
+	var calendar *MIMEPart
+	attachments := append([]Attachment(nil), message.Attachments...)
+	if message.Calendar != nil {
+		event := *message.Calendar
+		if event.Summary == "" {
+			event.Summary = message.Subject
+		}
+		if event.Organizer.Address == "" {
+			event.Organizer = from
+		}
+		if len(event.Attendees) == 0 {
+			event.Attendees = append(append([]mail.Address(nil), to...), cc...)
+		}
+		ics := RenderCalendar(event, b.now())
+		calendar = NewCalendarPart(event.Method, ics)
+		attachments = append(attachments, Attachment{Filename: calendarFilename, ContentType: "application/ics", Content: ics})
+	}
+
+	body := b.contentPart(message, bodyCharset, calendar)
+
+	// 非 HTML 邮件无法引用内联资源，作为普通附件发送
+	if !message.IsHTML {
+		attachments = append(attachments, message.Inlines...)
+	}
+	if len(attachments) > 0 {
+		parts := []*MIMEPart{body}
+		for _, attachment := range attachments {
+			parts = append(parts, NewAttachmentPart(attachment))
+		}
+		body = b.NewMultipart("mixed", nil, parts...)
+		body.Preamble = "This is a multi-part message in MIME format."
+	}
+
+	var buf bytes.Buffer
+	header.Write(&buf)
+	body.Write(&buf)
+	return buf.Bytes(), messageID, warnings
+}
+
+// contentPart 生成正文部分，HTML 邮件使用 multipart/alternative 同时提供纯文本和 HTML 两个版本，日历邀请放在最后
+// message.TextBody 已由 BuildMessage 补全
+func (b *MIMEBuilder) contentPart(message EmailMessage, charset *Charset, calendar *MIMEPart) *MIMEPart {
+	if !message.IsHTML {
+		text := NewTextPart("plain", message.Body, charset)
+		if calendar == nil {
+			return text
+		}
+		return b.NewMultipart("alternative", nil, text, calendar)
+	}
+
+	// 带内联资源时 HTML 使用 multipart/related
+	html := NewTextPart("html", message.Body, charset)
+	if len(message.Inlines) > 0 {
+		parts := []*MIMEPart{html}
+		for _, inline := range message.Inlines {
+			parts = append(parts, NewAttachmentPart(inline))
+		}
+		html = b.NewMultipart("related", []MIMEParam{{Name: "type", Value: "text/html"}}, parts...)
+	}
+	parts := []*MIMEPart{NewTextPart("plain", message.TextBody, charset), html}
+	if calendar != nil {
+		parts = append(parts, calendar)
+	}
+	return b.NewMultipart("alternative", nil, parts...)
+}
+
+// now 获取当前时间
+func (b *MIMEBuilder) now() time.Time {
+	if b.Now != nil {
+		return b.Now()
+	}
+	return time.Now()
+}
+
+// randomHex 从 reader 读取 n 字节生成十六进制字符串，reader 为 nil 时使用 crypto/rand
+func randomHex(reader io.Reader, n int) string {
+	if reader == nil {
+		reader = rand.Reader
+	}
+	data := make([]byte, n)
+	io.ReadFull(reader, data)
+	return hex.EncodeToString(data)
+}
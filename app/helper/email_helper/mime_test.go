@@ -0,0 +1,490 @@
+package email_helper
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// go test ./app/helper/email_helper -run TestBuildMessageGolden -update 重新生成 golden 文件
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 文件")
+
+// sequenceReader 按顺序输出递增字节，使分隔符和 Message-ID 固定
+type sequenceReader struct {
+	next byte
+}
+
+func (r *sequenceReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.next
+		r.next++
+	}
+	return len(p), nil
+}
+
+func newTestBuilder() *MIMEBuilder {
+	now := time.Date(2024, 5, 20, 10, 30, 0, 0, time.FixedZone("CST", 8*3600))
+	return &MIMEBuilder{
+		Now:  func() time.Time { return now },
+		Rand: &sequenceReader{},
+	}
+}
+
+var goldenCases = []struct {
+	name    string
+	from    mail.Address
+	to      []mail.Address
+	cc      []mail.Address
+	message EmailMessage
+}{
+	{
+		name: "plain_ascii",
+		from: mail.Address{Name: "Notifier", Address: "noreply@example.com"},
+		to:   []mail.Address{{Address: "user@example.org"}},
+		message: EmailMessage{
+			Subject: "Your weekly report",
+			Body:    "Hello,\n\nYour report is ready.\n\n-- \nNotifier",
+		},
+	},
+	{
+		name: "long_chinese_subject",
+		from: mail.Address{Name: "系统通知", Address: "noreply@example.com"},
+		to: []mail.Address{
+			{Name: "张三", Address: "zhangsan@example.com"},
+			{Name: "李四", Address: "lisi@example.com"},
+			{Name: "王五 (Wang Wu)", Address: "wangwu@example.com"},
+			{Address: "ops@example.com"},
+		},
+		cc: []mail.Address{{Name: "运维值班组的全体同事们请注意查收", Address: "oncall@example.com"}},
+		message: EmailMessage{
+			Subject: "【重要通知】关于二〇二四年第二季度系统升级维护期间服务暂停安排以及数据迁移注意事项的详细说明，请各部门务必提前做好准备",
+			Body:    "各位同事：\n\n本周六凌晨进行系统升级，届时服务暂停约两小时。\n\n谢谢配合！",
+		},
+	},
+	{
+		name: "quoted_printable",
+		from: mail.Address{Address: "noreply@example.com"},
+		to:   []mail.Address{{Name: "Zoë Müller", Address: "zoe@example.de"}},
+		message: EmailMessage{
+			Subject:  "Café menu for naïve readers",
+			Body:     "Bonjour Zoë,\n\nThe café opens at 8:00. This line is deliberately long so that it exceeds the seventy-six character limit of 7bit bodies.\nA=B  \n",
+			Priority: PriorityLow,
+		},
+	},
+	{
+		name: "html_inline_attachments",
+		from: mail.Address{Name: "Billing, Inc.", Address: "billing@example.com"},
+		to:   []mail.Address{{Name: "Customer", Address: "customer@example.com"}},
+		message: EmailMessage{
+			ReplyTo:  []mail.Address{{Name: "客服", Address: "support@example.com"}},
+			Subject:  "Invoice 2024-05",
+			Body:     `<p>您好，</p><p>附件是您五月份的账单。</p><img src="cid:logo">`,
+			IsHTML:   true,
+			Priority: PriorityHigh,
+			Headers:  map[string]string{"X-Campaign": "五月账单", "X-Tracking-Id": "abc-123"},
+			Inlines: []Attachment{
+				{Filename: "logo.png", ContentType: "image/png", Content: []byte("\x89PNG\r\n\x1a\nfake"), ContentID: "logo"},
+			},
+			Attachments: []Attachment{
+				{Filename: "二〇二四年五月份电子账单明细（含增值税专用发票信息）.pdf", ContentType: "application/pdf", Content: []byte("%PDF-1.4 fake")},
+				{Filename: "read me.txt", ContentType: "text/plain", Content: []byte("plain attachment")},
+			},
+		},
+	},
+	{
+		name: "gbk_html",
+		from: mail.Address{Name: "系统通知", Address: "noreply@example.com"},
+		to:   []mail.Address{{Name: "张三", Address: "zhangsan@example.com"}},
+		message: EmailMessage{
+			Subject: "关于系统升级维护期间服务暂停安排的通知，请各部门提前做好准备",
+			Body:    "<p>各位同事：</p><p>本周六凌晨进行系统升级，届时服务暂停约两小时。</p>",
+			IsHTML:  true,
+			Charset: "GBK",
+		},
+	},
+	{
+		name: "iso_2022_jp",
+		from: mail.Address{Name: "お知らせ", Address: "noreply@example.jp"},
+		to:   []mail.Address{{Name: "山田太郎", Address: "yamada@example.jp"}},
+		message: EmailMessage{
+			Subject: "システムメンテナンスのお知らせ（土曜日の深夜にサービスを停止します）",
+			Body:    "山田様\n\n土曜日の深夜にシステムメンテナンスを行います。\nよろしくお願いいたします。",
+			Charset: "ISO-2022-JP",
+		},
+	},
+	{
+		name: "thread_reply",
+		from: mail.Address{Name: "Alerts", Address: "alerts@example.com"},
+		to:   []mail.Address{{Address: "oncall@example.com"}},
+		message: EmailMessage{
+			Subject:   "[INC-1024] Database latency high",
+			Body:      "Update: latency is back to normal.",
+			ThreadKey: "INC-1024",
+			InReplyTo: "lrx3k2a1.0f1e2d3c4b5a69788796a5b4c3d2e1f0@example.com",
+			References: []string{
+				"lrx3a9q0.00112233445566778899aabbccddeeff@example.com",
+				"lrx3f7z2.ffeeddccbbaa99887766554433221100@example.com",
+				"lrx3k2a1.0f1e2d3c4b5a69788796a5b4c3d2e1f0@example.com",
+			},
+		},
+	},
+	{
+		name: "calendar_request",
+		from: mail.Address{Name: "会议助手", Address: "calendar@example.com"},
+		to:   []mail.Address{{Name: "张三", Address: "zhangsan@example.com"}, {Address: "lisi@example.com"}},
+		message: EmailMessage{
+			Subject: "项目周会",
+			Body:    "<p>请准时参加项目周会。</p>",
+			IsHTML:  true,
+			Calendar: &CalendarEvent{
+				Method:      CalendarMethodRequest,
+				UID:         "weekly-sync-20240521@example.com",
+				Sequence:    1,
+				Description: "议程：\n1. 进度同步; 2. 风险评审, 3. 下周计划",
+				Location:    "3 楼会议室（Room 301）",
+				Start:       time.Date(2024, 5, 21, 2, 0, 0, 0, time.UTC),
+				End:         time.Date(2024, 5, 21, 3, 0, 0, 0, time.UTC),
+				Timezone:    "Asia/Shanghai",
+			},
+		},
+	},
+}
+
+func TestBuildMessageGolden(t *testing.T) {
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, messageID, _ := newTestBuilder().BuildMessage(tc.from, tc.to, tc.cc, tc.message)
+
+			golden := filepath.Join("testdata", tc.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, msg, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("读取 golden 文件失败: %v（使用 -update 生成）", err)
+			}
+			if !bytes.Equal(msg, want) {
+				t.Errorf("邮件内容与 %s 不一致\n--- got ---\n%s\n--- want ---\n%s", golden, msg, want)
+			}
+
+			checkLineLength(t, msg)
+			checkRoundTrip(t, msg, messageID, tc.from, tc.to, tc.message)
+		})
+	}
+}
+
+// checkLineLength 信头折行后、正文编码后每行都不超过 76 个字符
+func checkLineLength(t *testing.T, msg []byte) {
+	for _, line := range strings.Split(string(msg), "\n") {
+		if len(line) > headerLineLength {
+			t.Errorf("行超过 %d 个字符（%d）: %s", headerLineLength, len(line), line)
+		}
+	}
+}
+
+// checkRoundTrip 解析生成的邮件，解码后的信头和正文与原始内容一致
+func checkRoundTrip(t *testing.T, msg []byte, messageID string, from mail.Address, to []mail.Address, message EmailMessage) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(bytes.ReplaceAll(msg, []byte("\n"), []byte("\r\n"))))
+	if err != nil {
+		t.Fatalf("解析邮件失败: %v", err)
+	}
+
+	if message.Charset != "" && !strings.HasPrefix(parsed.Header.Get("Subject"), "=?"+message.Charset+"?B?") {
+		t.Errorf("Subject 应使用 %s 编码: %s", message.Charset, parsed.Header.Get("Subject"))
+	}
+	if got := parsed.Header.Get("Message-ID"); got != "<"+messageID+">" {
+		t.Errorf("Message-ID = %s，期望 <%s>", got, messageID)
+	}
+	if !strings.HasSuffix(messageID, "@"+strings.SplitN(from.Address, "@", 2)[1]) {
+		t.Errorf("Message-ID 应使用发件人域名: %s", messageID)
+	}
+
+	decoder := &mime.WordDecoder{CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		enc, err := ianaindex.MIME.Encoding(charset)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}}
+	subject, err := decoder.DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != message.Subject {
+		t.Errorf("Subject 解码为 %q（%v），期望 %q", subject, err, message.Subject)
+	}
+
+	addresses, err := (&mail.AddressParser{WordDecoder: decoder}).ParseList(parsed.Header.Get("To"))
+	if err != nil {
+		t.Fatalf("解析 To 失败: %v", err)
+	}
+	if len(addresses) != len(to) {
+		t.Fatalf("To 数量为 %d，期望 %d", len(addresses), len(to))
+	}
+	for i, address := range addresses {
+		if address.Name != to[i].Name || address.Address != to[i].Address {
+			t.Errorf("To[%d] = %q <%s>，期望 %q <%s>", i, address.Name, address.Address, to[i].Name, to[i].Address)
+		}
+	}
+
+	parts := make(map[string]string)
+	collectParts(t, parsed.Header.Get("Content-Type"), parsed.Header.Get("Content-Transfer-Encoding"), "", parsed.Body, parts)
+
+	bodyType := "text/plain"
+	if message.IsHTML {
+		bodyType = "text/html"
+	}
+	wantBody := message.Body
+	if !strings.HasPrefix(parsed.Header.Get("Content-Type"), "multipart/") && parsed.Header.Get("Content-Transfer-Encoding") != EncodingBase64 {
+		// 单部分邮件没有分隔符，7bit 和 quoted-printable 末尾补充的换行保留在正文中
+		wantBody += "\n"
+	}
+	if got := parts[bodyType]; got != wantBody {
+		t.Errorf("%s 正文解码为 %q，期望 %q", bodyType, got, wantBody)
+	}
+	for _, attachment := range append(message.Attachments, message.Inlines...) {
+		if got, ok := parts[attachment.Filename]; !ok || got != string(attachment.Content) {
+			t.Errorf("附件 %s 解码为 %q，期望 %q", attachment.Filename, got, attachment.Content)
+		}
+	}
+}
+
+// collectParts 递归解码 MIME 部分，正文按类型、附件按文件名保存
+func collectParts(t *testing.T, contentType string, encoding string, disposition string, body io.Reader, parts map[string]string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("解析 Content-Type 失败: %v", err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatalf("读取 multipart 失败: %v", err)
+			}
+			// multipart.Reader 会自动解码 quoted-printable 并删除 Content-Transfer-Encoding
+			collectParts(t, part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"),
+				part.Header.Get("Content-Disposition"), part, parts)
+		}
+	}
+
+	switch strings.ToLower(encoding) {
+	case EncodingBase64:
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case EncodingQuotedPrintable:
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("解码内容失败: %v", err)
+	}
+
+	if disposition != "" {
+		_, dispositionParams, err := mime.ParseMediaType(disposition)
+		if err != nil {
+			t.Fatalf("解析 Content-Disposition 失败: %v", err)
+		}
+		parts[dispositionParams["filename"]] = string(data)
+		return
+	}
+	if charset := params["charset"]; charset != "" && !strings.EqualFold(charset, "UTF-8") {
+		enc, err := ianaindex.MIME.Encoding(charset)
+		if err != nil {
+			t.Fatalf("未知字符集 %s: %v", charset, err)
+		}
+		if data, err = enc.NewDecoder().Bytes(data); err != nil {
+			t.Fatalf("%s 解码失败: %v", charset, err)
+		}
+	}
+	parts[mediaType] = strings.ReplaceAll(string(data), "\r\n", "\n")
+}
+
+func TestBuildMessageCharsetFallback(t *testing.T) {
+	from := mail.Address{Name: "通知", Address: "noreply@example.com"}
+	to := []mail.Address{{Name: "陳大文", Address: "chan@example.com.tw"}}
+	cases := []struct {
+		name         string
+		message      EmailMessage
+		subject      string // Subject 编码字的字符集
+		body         string // 正文的 charset 参数
+		warningCount int
+	}{
+		{"全部可以表示", EmailMessage{Subject: "繁體中文通知", Body: "您好，這是測試郵件。", Charset: "big5"}, "Big5", "Big5", 0},
+		{"主题无法表示", EmailMessage{Subject: "简体中文通知", Body: "您好，這是測試郵件。", Charset: "big5"}, "UTF-8", "Big5", 1},
+		{"正文无法表示", EmailMessage{Subject: "繁體中文通知", Body: "您好 😀", Charset: "big5"}, "Big5", "UTF-8", 1},
+		{"GB18030 可以表示所有字符", EmailMessage{Subject: "简体 繁體 😀", Body: "您好 😀", Charset: "GB18030"}, "GB18030", "GB18030", 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, _, warnings := newTestBuilder().BuildMessage(from, to, nil, tc.message)
+			if len(warnings) != tc.warningCount {
+				t.Errorf("警告为 %v，期望 %d 条", warnings, tc.warningCount)
+			}
+			parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+			if err != nil {
+				t.Fatalf("解析邮件失败: %v", err)
+			}
+			if subject := parsed.Header.Get("Subject"); !strings.HasPrefix(subject, "=?"+tc.subject+"?B?") {
+				t.Errorf("Subject = %s，期望使用 %s 编码", subject, tc.subject)
+			}
+			_, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+			if params["charset"] != tc.body {
+				t.Errorf("正文 charset = %s，期望 %s", params["charset"], tc.body)
+			}
+		})
+	}
+}
+
+func TestLookupCharset(t *testing.T) {
+	cases := map[string]string{
+		"":            "UTF-8",
+		"utf8":        "UTF-8",
+		"gb18030":     "GB18030",
+		"gb2312":      "GBK",
+		"BIG5":        "Big5",
+		"iso-2022-jp": "ISO-2022-JP",
+		"shift_jis":   "Shift_JIS",
+	}
+	for name, want := range cases {
+		charset, err := LookupCharset(name)
+		if err != nil || charset.Name != want {
+			t.Errorf("LookupCharset(%q) = %v（%v），期望 %s", name, charset, err, want)
+		}
+	}
+	for _, name := range []string{"utf-16", "unknown"} {
+		if _, err := LookupCharset(name); err == nil {
+			t.Errorf("LookupCharset(%q) 应返回错误", name)
+		}
+	}
+}
+
+func TestChooseTransferEncoding(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{"", Encoding7Bit},
+		{"Hello\nWorld", Encoding7Bit},
+		{strings.Repeat("a", 77), EncodingQuotedPrintable},
+		{"Café au lait, s'il vous plaît", EncodingQuotedPrintable},
+		{"各位同事，本周六凌晨进行系统升级", EncodingBase64},
+		{"\x00\x01\x02binary", EncodingBase64},
+		{"\x1b$B;3ED\x1b(B", Encoding7Bit},
+	}
+	for _, tc := range cases {
+		if got := ChooseTransferEncoding([]byte(tc.body)); got != tc.want {
+			t.Errorf("ChooseTransferEncoding(%q) = %s，期望 %s", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestNewMessageIDUnique(t *testing.T) {
+	builder := NewMIMEBuilder()
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := builder.NewMessageID("sender@Example.COM")
+		if seen[id] {
+			t.Fatalf("Message-ID 重复: %s", id)
+		}
+		seen[id] = true
+		if !strings.HasSuffix(id, "@example.com") {
+			t.Fatalf("Message-ID 应使用发件人域名: %s", id)
+		}
+	}
+}
+
+func TestRenderCalendarTimezone(t *testing.T) {
+	cases := []struct {
+		timezone string
+		start    time.Time
+		want     []string
+	}{
+		{"UTC", time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC), []string{"DTSTART:20240701T090000Z"}},
+		{"Asia/Shanghai", time.Date(2024, 7, 1, 1, 0, 0, 0, time.UTC), []string{
+			"DTSTART;TZID=Asia/Shanghai:20240701T090000", "DTSTART:19700101T000000", "TZOFFSETTO:+0800",
+		}},
+		{"America/New_York", time.Date(2024, 7, 1, 13, 0, 0, 0, time.UTC), []string{
+			"DTSTART;TZID=America/New_York:20240701T090000",
+			"BEGIN:DAYLIGHT\r\nDTSTART:20240310T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400",
+			"BEGIN:STANDARD\r\nDTSTART:20241103T020000\r\nRRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500",
+		}},
+		{"Europe/Berlin", time.Date(2024, 7, 1, 7, 0, 0, 0, time.UTC), []string{
+			"RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU", "RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU",
+		}},
+	}
+	for _, tc := range cases {
+		event := CalendarEvent{UID: "uid@example.com", Start: tc.start, End: tc.start.Add(time.Hour), Timezone: tc.timezone}
+		if err := NormalizeCalendarEvent(&event); err != nil {
+			t.Fatalf("%s: %v", tc.timezone, err)
+		}
+		ics := string(RenderCalendar(event, tc.start))
+		for _, want := range tc.want {
+			if !strings.Contains(ics, want) {
+				t.Errorf("%s 缺少 %q\n%s", tc.timezone, want, ics)
+			}
+		}
+	}
+}
+
+func TestWriteICSLine(t *testing.T) {
+	var buf bytes.Buffer
+	writeICSLine(&buf, "DESCRIPTION:"+strings.Repeat("会议议程", 20))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	if len(lines) < 2 {
+		t.Fatalf("长内容应折行: %q", buf.String())
+	}
+	var unfolded string
+	for i, line := range lines {
+		if len(line) > icsLineLength {
+			t.Errorf("第 %d 行超过 %d 字节: %q", i+1, icsLineLength, line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("第 %d 行拆分了 UTF-8 字符: %q", i+1, line)
+		}
+		if i > 0 {
+			line = strings.TrimPrefix(line, " ")
+		}
+		unfolded += line
+	}
+	if unfolded != "DESCRIPTION:"+strings.Repeat("会议议程", 20) {
+		t.Errorf("折行还原后不一致: %q", unfolded)
+	}
+}
+
+func TestNormalizeCalendarEvent(t *testing.T) {
+	start := time.Date(2024, 5, 21, 2, 0, 0, 0, time.UTC)
+	cases := []struct {
+		event CalendarEvent
+		ok    bool
+	}{
+		{CalendarEvent{UID: "a", Start: start, End: start.Add(time.Hour)}, true},
+		{CalendarEvent{Method: "cancel", UID: "a", Start: start, End: start.Add(time.Hour)}, true},
+		{CalendarEvent{Method: "PUBLISH", UID: "a", Start: start, End: start.Add(time.Hour)}, false},
+		{CalendarEvent{Start: start, End: start.Add(time.Hour)}, false},
+		{CalendarEvent{UID: "a", Start: start, End: start}, false},
+		{CalendarEvent{UID: "a", Sequence: -1, Start: start, End: start.Add(time.Hour)}, false},
+		{CalendarEvent{UID: "a", Start: start, End: start.Add(time.Hour), Timezone: "Mars/Olympus"}, false},
+	}
+	for i, tc := range cases {
+		err := NormalizeCalendarEvent(&tc.event)
+		if (err == nil) != tc.ok {
+			t.Errorf("第 %d 个用例: err = %v，期望成功 %v", i+1, err, tc.ok)
+		}
+	}
+}
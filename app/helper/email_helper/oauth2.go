@@ -0,0 +1,179 @@
+package email_helper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 令牌过期前提前刷新的时间
+const tokenExpiryDelta = 60 * time.Second
+
+var errNoTokenSource = errors.New("XOAUTH2 认证需要配置 OAuth2 访问令牌或令牌接口")
+
+// TokenSource XOAUTH2 认证使用的访问令牌来源
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+var (
+	// 通过 RegisterTokenSource 为账号注册的令牌来源
+	registeredTokenSources = make(map[string]TokenSource)
+	// 根据配置创建的令牌接口来源，缓存访问令牌直到过期
+	refreshTokenSources = make(map[string]*refreshTokenSource)
+	tokenSourcesMu      sync.Mutex
+)
+
+// RegisterTokenSource 为 SMTP 账号注册自定义令牌来源，优先于配置中的令牌接口
+func RegisterTokenSource(account string, source TokenSource) {
+	account = strings.ToLower(strings.TrimSpace(account))
+	if account == "" {
+		account = DefaultAccount
+	}
+	tokenSourcesMu.Lock()
+	defer tokenSourcesMu.Unlock()
+	if source == nil {
+		delete(registeredTokenSources, account)
+		return
+	}
+	registeredTokenSources[account] = source
+}
+
+// getTokenSource 获取账号的令牌来源：自定义注册 > 令牌接口 > 固定访问令牌，均未配置时返回 nil
+func getTokenSource(config EmailConfig) TokenSource {
+	tokenSourcesMu.Lock()
+	defer tokenSourcesMu.Unlock()
+
+	if source, ok := registeredTokenSources[config.Account]; ok {
+		return source
+	}
+	if config.OAuth2TokenURL != "" {
+		key := strings.Join([]string{config.OAuth2TokenURL, config.OAuth2ClientID, config.OAuth2ClientSecret, config.OAuth2RefreshToken, config.OAuth2Scope}, "|")
+		source, ok := refreshTokenSources[key]
+		if !ok {
+			source = &refreshTokenSource{
+				tokenURL:     config.OAuth2TokenURL,
+				clientID:     config.OAuth2ClientID,
+				clientSecret: config.OAuth2ClientSecret,
+				refreshToken: config.OAuth2RefreshToken,
+				scope:        config.OAuth2Scope,
+				client:       &http.Client{Timeout: timeoutOrDefault(config.CommandTimeout, defaultCommandTimeout)},
+			}
+			refreshTokenSources[key] = source
+		}
+		return source
+	}
+	if config.OAuth2AccessToken != "" {
+		return staticTokenSource(config.OAuth2AccessToken)
+	}
+	return nil
+}
+
+// staticTokenSource 固定的访问令牌，由外部负责更新
+type staticTokenSource string
+
+func (s staticTokenSource) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+// refreshTokenSource 从令牌接口获取访问令牌
+// 配置了 refresh_token 时使用 refresh_token 授权，否则使用 client_credentials 授权
+type refreshTokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	refreshToken string
+	scope        string
+	client       *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// tokenResponse 令牌接口返回内容
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Token 获取访问令牌，缓存的令牌未过期时直接返回
+func (s *refreshTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && (s.expiresAt.IsZero() || time.Now().Before(s.expiresAt.Add(-tokenExpiryDelta))) {
+		return s.accessToken, nil
+	}
+
+	form := url.Values{}
+	if s.refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", s.refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	form.Set("client_id", s.clientID)
+	if s.clientSecret != "" {
+		form.Set("client_secret", s.clientSecret)
+	}
+	if s.scope != "" {
+		form.Set("scope", s.scope)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("令牌接口返回格式错误（HTTP %d）: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if token.Error != "" {
+		return "", fmt.Errorf("令牌接口返回错误: %s %s", token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("令牌接口未返回访问令牌（HTTP %d）", resp.StatusCode)
+	}
+
+	s.accessToken = token.AccessToken
+	s.expiresAt = time.Time{}
+	if token.ExpiresIn > 0 {
+		s.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	// 部分服务商每次刷新都会轮换 refresh_token
+	if token.RefreshToken != "" {
+		s.refreshToken = token.RefreshToken
+	}
+	return s.accessToken, nil
+}
+
+// invalidate 清除缓存的访问令牌，下次获取时重新请求令牌接口
+func (s *refreshTokenSource) invalidate() {
+	s.mu.Lock()
+	s.accessToken = ""
+	s.mu.Unlock()
+}
@@ -0,0 +1,85 @@
+package email_helper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAuthXOAuth2RefreshToken(t *testing.T) {
+	// 令牌接口每次刷新签发新的访问令牌并轮换 refresh_token
+	var mu sync.Mutex
+	var requests []string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		requests = append(requests, r.PostForm.Get("refresh_token"))
+		n := len(requests)
+		mu.Unlock()
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("client_id") != "client-id" || r.PostForm.Get("client_secret") != "client-secret" {
+			t.Errorf("令牌请求参数错误: %v", r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  fmt.Sprintf("access-%d", n),
+			"expires_in":    3600,
+			"refresh_token": fmt.Sprintf("refresh-%d", n+1),
+		})
+	}))
+	defer tokenServer.Close()
+
+	// 第一个访问令牌已被吊销
+	server := startFakeAuthServer(t, "XOAUTH2", xoauth2Server(func(token string) bool { return token != "access-1" }))
+	config := server.config(AuthXOAuth2)
+	config.OAuth2TokenURL = tokenServer.URL
+	config.OAuth2ClientID = "client-id"
+	config.OAuth2ClientSecret = "client-secret"
+	config.OAuth2RefreshToken = "refresh-1"
+
+	// 服务器 334/535 拒绝令牌后清除缓存，下次发送重新获取
+	if err := dialAuth(config); replyCode(err) != 535 {
+		t.Fatalf("令牌被吊销时应返回 535，实际: %v", err)
+	}
+	if err := dialAuth(config); err != nil {
+		t.Fatalf("重新获取令牌后认证失败: %v", err)
+	}
+	// 令牌未过期时使用缓存
+	if err := dialAuth(config); err != nil {
+		t.Fatalf("使用缓存的令牌认证失败: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("令牌接口请求次数 = %d，期望 2", len(requests))
+	}
+	// 第二次刷新使用轮换后的 refresh_token
+	if requests[0] != "refresh-1" || requests[1] != "refresh-2" {
+		t.Errorf("refresh_token = %v", requests)
+	}
+}
+
+func TestRefreshTokenSourceError(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+	}))
+	defer tokenServer.Close()
+
+	server := startFakeAuthServer(t, "XOAUTH2", xoauth2Server(func(token string) bool { return true }))
+	config := server.config(AuthXOAuth2)
+	config.OAuth2TokenURL = tokenServer.URL
+	config.OAuth2ClientID = "client-id"
+	config.OAuth2RefreshToken = "revoked"
+	err := dialAuth(config)
+	if err == nil || replyCode(err) != 0 {
+		t.Fatalf("令牌接口返回错误时应在认证前失败，实际: %v", err)
+	}
+	if !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("错误信息应包含令牌接口返回的错误: %v", err)
+	}
+}
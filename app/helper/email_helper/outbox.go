@@ -0,0 +1,346 @@
+package email_helper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/helper/helper"
+	"gin_base/app/helper/log_helper"
+	"gin_base/app/helper/type_helper"
+	"gin_base/app/model"
+	"math/rand"
+	"time"
+)
+
+// 发送队列状态
+const (
+	OutboxStatusScheduled = "scheduled" // 已预约，到达预约时间后转为待投递
+	OutboxStatusPending   = "pending"   // 待投递，到达下次投递时间后由工作协程投递
+	OutboxStatusSending   = "sending"   // 投递中
+	OutboxStatusSent      = "sent"      // 已发送
+	OutboxStatusFailed    = "failed"    // 永久错误或达到最大投递次数
+	OutboxStatusCanceled  = "canceled"  // 已取消
+)
+
+const (
+	// 投递中超过该时间未结束的邮件视为进程已退出，重新投递
+	outboxStaleTimeout = 10 * time.Minute
+	// 队列配置的默认值
+	defaultQueueMaxAttempts  = 6
+	defaultQueueRetryBase    = 30 * time.Second
+	defaultQueueRetryMax     = time.Hour
+	defaultQueuePollInterval = 5 * time.Second
+)
+
+// QueueSettings 异步发送队列配置，读取 app/appconfig/email.yaml
+type QueueSettings struct {
+	Workers      int           // 工作协程数，0 表示当前实例不投递
+	MaxAttempts  int           // 最大投递次数（含第一次）
+	RetryBase    time.Duration // 第一次重试的等待时间，之后每次翻倍
+	RetryMax     time.Duration // 重试等待时间上限
+	PollInterval time.Duration // 轮询间隔
+}
+
+// EnqueueOptions 邮件入队选项
+type EnqueueOptions struct {
+	FromName    string      // 发件人名称，为空时使用账号配置
+	RequestData interface{} // 写入发送记录的请求参数
+	SendAt      time.Time   // 预约发送时间，零值时立即发送
+	ResendOf    uint        // 重发的原始发送记录ID
+	BatchId     string      // 个性化批量发送ID
+}
+
+// 新邮件入队时唤醒调度协程
+var outboxWake = make(chan struct{}, 1)
+
+// GetQueueSettings 获取异步发送队列配置，未配置的项使用默认值
+func GetQueueSettings() QueueSettings {
+	emailConfig := helper.GetAppConfig().Email
+	settings := QueueSettings{
+		Workers:      emailConfig.Queue_Workers,
+		MaxAttempts:  emailConfig.Queue_Max_Attempts,
+		RetryBase:    time.Duration(emailConfig.Queue_Retry_Base) * time.Second,
+		RetryMax:     time.Duration(emailConfig.Queue_Retry_Max) * time.Second,
+		PollInterval: time.Duration(emailConfig.Queue_Poll_Interval) * time.Second,
+	}
+	if settings.MaxAttempts <= 0 {
+		settings.MaxAttempts = defaultQueueMaxAttempts
+	}
+	if settings.RetryBase <= 0 {
+		settings.RetryBase = defaultQueueRetryBase
+	}
+	if settings.RetryMax < settings.RetryBase {
+		settings.RetryMax = defaultQueueRetryMax
+	}
+	if settings.PollInterval <= 0 {
+		settings.PollInterval = defaultQueuePollInterval
+	}
+	return settings
+}
+
+// EnqueueEmail 将邮件写入发送队列，立即返回队列记录，Message-ID 在入队时生成
+// 设置了预约发送时间时到达预约时间后才投递
+func EnqueueEmail(requestIP string, account string, message EmailMessage, options EnqueueOptions) (model.EmailOutbox, error) {
+	config, err := GetAccountConfig(account)
+	if err != nil {
+		return model.EmailOutbox{}, err
+	}
+	if message.MessageID == "" {
+		message.MessageID = NewMIMEBuilder().NewMessageID(config.From)
+	}
+	messageJSON, err := json.Marshal(message)
+	if err != nil {
+		return model.EmailOutbox{}, fmt.Errorf("邮件内容序列化失败: %v", err)
+	}
+	requestDataJSON := ""
+	if options.RequestData != nil {
+		if jsonData, err := json.Marshal(options.RequestData); err == nil {
+			requestDataJSON = string(jsonData)
+		}
+	}
+
+	outbox := model.EmailOutbox{
+		Status:        OutboxStatusPending,
+		Account:       config.Account,
+		FromName:      options.FromName,
+		RequestIP:     requestIP,
+		ToEmail:       FormatAddressList(message.To),
+		Subject:       message.Subject,
+		MessageId:     message.MessageID,
+		Message:       string(messageJSON),
+		RequestData:   requestDataJSON,
+		MaxAttempts:   GetQueueSettings().MaxAttempts,
+		NextAttemptAt: type_helper.Time(time.Now()),
+		ResendOf:      options.ResendOf,
+		BatchId:       options.BatchId,
+	}
+	if !options.SendAt.IsZero() {
+		scheduledAt := type_helper.Time(options.SendAt)
+		outbox.Status = OutboxStatusScheduled
+		outbox.SendAt = &scheduledAt
+		outbox.NextAttemptAt = scheduledAt
+	}
+	if err := db_helper.Db().Create(&outbox).Error; err != nil {
+		return model.EmailOutbox{}, fmt.Errorf("邮件入队失败: %v", err)
+	}
+	SaveThreadMessage(message, outbox.MessageId, outbox.Id)
+	if outbox.Status == OutboxStatusPending {
+		NotifyOutbox()
+	}
+	return outbox, nil
+}
+
+// NotifyOutbox 唤醒调度协程立即检查队列
+func NotifyOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartOutboxWorkers 启动发送队列的调度协程和工作协程，工作协程数为 0 时不启动
+// 多个实例可以同时运行，每封邮件通过条件更新状态认领，只会被一个实例投递
+func StartOutboxWorkers() {
+	settings := GetQueueSettings()
+	if settings.Workers <= 0 {
+		return
+	}
+
+	// idle 中的令牌数为空闲的工作协程数，调度协程只认领与空闲工作协程数量相同的邮件
+	// 认领后立即开始投递，避免邮件在排队等待时超过 outboxStaleTimeout 被重复认领
+	jobs := make(chan model.EmailOutbox, settings.Workers)
+	idle := make(chan struct{}, settings.Workers)
+	for i := 0; i < settings.Workers; i++ {
+		idle <- struct{}{}
+		go func() {
+			for outbox := range jobs {
+				deliverOutbox(outbox, settings)
+				idle <- struct{}{}
+				NotifyOutbox()
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(settings.PollInterval)
+		defer ticker.Stop()
+		for {
+			dispatchOutbox(jobs, idle)
+			select {
+			case <-ticker.C:
+			case <-outboxWake:
+			}
+		}
+	}()
+}
+
+// dispatchOutbox 认领到达投递时间的邮件交给空闲的工作协程，直到没有待投递的邮件或没有空闲的工作协程
+func dispatchOutbox(jobs chan<- model.EmailOutbox, idle chan struct{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			log_helper.Error(fmt.Sprintf("发送队列调度panic: %v", r))
+		}
+	}()
+
+	// 投递中超时的邮件（进程在投递过程中退出）重新投递
+	db_helper.Db().Model(&model.EmailOutbox{}).
+		Where("status = ? AND locked_at < ?", OutboxStatusSending, time.Now().Add(-outboxStaleTimeout)).
+		Updates(map[string]interface{}{"status": OutboxStatusPending, "locked_at": nil})
+
+	for {
+		free := len(idle)
+		if free == 0 {
+			return
+		}
+		var outboxes []model.EmailOutbox
+		db_helper.Db().
+			Where("status = ? AND next_attempt_at <= ?", OutboxStatusPending, time.Now()).
+			Order("next_attempt_at, id").
+			Limit(free).
+			Find(&outboxes)
+		for _, outbox := range outboxes {
+			// 只有调度协程取出令牌，free 个令牌一定可以取到
+			<-idle
+			now := type_helper.Time(time.Now())
+			claimed := db_helper.Db().Model(&model.EmailOutbox{}).
+				Where("id = ? AND status = ?", outbox.Id, OutboxStatusPending).
+				Updates(map[string]interface{}{"status": OutboxStatusSending, "locked_at": now})
+			if claimed.Error != nil || claimed.RowsAffected == 0 {
+				idle <- struct{}{}
+				continue
+			}
+			outbox.Status = OutboxStatusSending
+			outbox.LockedAt = &now
+			jobs <- outbox
+		}
+		if len(outboxes) < free {
+			return
+		}
+	}
+}
+
+// deliverOutbox 投递一封队列中的邮件并记录发送记录，临时错误时按退避时间重新排队
+func deliverOutbox(outbox model.EmailOutbox, settings QueueSettings) {
+	attempts := outbox.Attempts + 1
+	defer func() {
+		if r := recover(); r != nil {
+			log_helper.Error(fmt.Sprintf("投递队列邮件panic: %v", r))
+			finishOutbox(outbox.Id, attempts, OutboxStatusFailed, fmt.Sprintf("投递异常: %v", r), "", 0, time.Time{})
+		}
+	}()
+
+	result, emailLogId := sendOutbox(outbox, attempts)
+
+	status := OutboxStatusFailed
+	var nextAttemptAt time.Time
+	switch {
+	case result.Success:
+		status = OutboxStatusSent
+	case shouldFailover(result.ErrorType) && attempts < outbox.MaxAttempts:
+		status = OutboxStatusPending
+		nextAttemptAt = time.Now().Add(RetryDelay(attempts, settings.RetryBase, settings.RetryMax, rand.Int63n))
+	}
+	finishOutbox(outbox.Id, attempts, status, result.Error, result.ErrorType, emailLogId, nextAttemptAt)
+}
+
+// sendOutbox 发送队列中的邮件，返回发送结果和发送记录ID
+func sendOutbox(outbox model.EmailOutbox, attempts int) (EmailResult, uint) {
+	var message EmailMessage
+	if err := json.Unmarshal([]byte(outbox.Message), &message); err != nil {
+		return EmailResult{Error: "邮件内容解析失败: " + err.Error(), ErrorType: ErrorTypePermanent}, 0
+	}
+
+	// 投递前重新检查抑制列表，入队后退订或退信的收件人不再投递
+	var skipped []RecipientResult
+	message.To, message.Cc, message.Bcc, skipped = FilterSuppressed(message.Category, message.To, message.Cc, message.Bcc)
+
+	var result EmailResult
+	configs, err := GetFailoverChain(outbox.Account)
+	switch {
+	case err != nil:
+		result = EmailResult{Error: err.Error(), ErrorType: ErrorTypePermanent}
+		configs = []EmailConfig{{Account: outbox.Account}}
+	case len(message.To) == 0:
+		result = EmailResult{Error: "收件人均在抑制列表中", ErrorType: ErrorTypePermanent}
+	default:
+		if outbox.FromName != "" {
+			for i := range configs {
+				configs[i].FromName = outbox.FromName
+			}
+		}
+		result = SendEmailWithFailover(context.Background(), configs, message)
+	}
+	result.Recipients = append(result.Recipients, skipped...)
+	if result.MessageID == "" {
+		result.MessageID = outbox.MessageId
+	}
+
+	var requestData interface{}
+	if outbox.RequestData != "" {
+		requestData = json.RawMessage(outbox.RequestData)
+	}
+	emailLog := NewEmailLog(outbox.RequestIP, message, configs[0], result, requestData)
+	emailLog.OutboxId = outbox.Id
+	emailLog.ResendOf = outbox.ResendOf
+	emailLog.BatchId = outbox.BatchId
+	if err := SaveEmailLog(&emailLog, result); err != nil {
+		return result, 0
+	}
+	return result, emailLog.Id
+}
+
+// finishOutbox 更新投递结果，nextAttemptAt 为重新排队的投递时间
+func finishOutbox(id uint, attempts int, status string, lastError string, errorType string, emailLogId uint, nextAttemptAt time.Time) {
+	updates := map[string]interface{}{
+		"status":     status,
+		"attempts":   attempts,
+		"last_error": lastError,
+		"error_type": errorType,
+		"locked_at":  nil,
+	}
+	if emailLogId > 0 {
+		updates["email_log_id"] = emailLogId
+	}
+	if status == OutboxStatusSent {
+		updates["sent_at"] = type_helper.Time(time.Now())
+	}
+	if !nextAttemptAt.IsZero() {
+		updates["next_attempt_at"] = type_helper.Time(nextAttemptAt)
+	}
+	if err := db_helper.Db().Model(&model.EmailOutbox{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		log_helper.Error(fmt.Sprintf("更新发送队列状态失败: %v", err))
+	}
+}
+
+// RetryDelay 第 attempts 次投递失败后的重试等待时间：retryBase * 2^(attempts-1)，不超过 retryMax
+// 加入随机抖动（等待时间的一半固定，另一半随机），避免大量邮件在同一时刻重试，random 返回 [0, n) 的随机数
+func RetryDelay(attempts int, retryBase time.Duration, retryMax time.Duration, random func(n int64) int64) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempts && delay < retryMax; i++ {
+		delay *= 2
+	}
+	if delay > retryMax {
+		delay = retryMax
+	}
+	half := delay / 2
+	return half + time.Duration(random(int64(delay-half)+1))
+}
+
+// GetOutbox 按队列ID或 Message-ID 查询发送队列记录
+func GetOutbox(id uint, messageID string) (model.EmailOutbox, error) {
+	var outbox model.EmailOutbox
+	db := db_helper.Db()
+	if id > 0 {
+		db = db.Where("id = ?", id)
+	} else if messageID != "" {
+		db = db.Where("message_id = ?", NormalizeMessageID(messageID))
+	} else {
+		return outbox, errors.New("队列ID和 Message-ID 不能同时为空")
+	}
+	if err := db.First(&outbox).Error; err != nil {
+		return outbox, errors.New("发送队列记录不存在")
+	}
+	return outbox, nil
+}
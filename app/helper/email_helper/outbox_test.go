@@ -0,0 +1,55 @@
+package email_helper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	base, max := 30*time.Second, 10*time.Minute
+	cases := []struct {
+		attempts int
+		delay    time.Duration // 不含抖动的等待时间
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute},
+		{30, 10 * time.Minute},
+	}
+	for _, tc := range cases {
+		// 抖动范围为 [delay/2, delay]
+		low := RetryDelay(tc.attempts, base, max, func(n int64) int64 { return 0 })
+		high := RetryDelay(tc.attempts, base, max, func(n int64) int64 { return n - 1 })
+		if low != tc.delay/2 || high != tc.delay {
+			t.Errorf("第 %d 次失败后的等待时间为 [%v, %v]，期望 [%v, %v]", tc.attempts, low, high, tc.delay/2, tc.delay)
+		}
+	}
+}
+
+func TestParseSendAt(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sendAt, err := ParseSendAt("", now)
+	if err != nil || !sendAt.IsZero() {
+		t.Errorf("为空时应立即发送: %v, %v", sendAt, err)
+	}
+	sendAt, err = ParseSendAt("2030-01-02T09:00:00+08:00", now)
+	if err != nil || !sendAt.Equal(time.Date(2030, 1, 2, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 解析结果 = %v, %v", sendAt, err)
+	}
+	sendAt, err = ParseSendAt("1893542400", now)
+	if err != nil || !sendAt.Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unix 时间戳解析结果 = %v, %v", sendAt, err)
+	}
+	// 允许少量时钟误差
+	if _, err := ParseSendAt("2029-12-31T23:59:30Z", now); err != nil {
+		t.Errorf("时钟误差范围内应允许: %v", err)
+	}
+	for _, value := range []string{"2029-12-31T23:00:00Z", "2031-06-01T00:00:00Z", "tomorrow"} {
+		if _, err := ParseSendAt(value, now); err == nil {
+			t.Errorf("%s 应返回错误", value)
+		}
+	}
+}
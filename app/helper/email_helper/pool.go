@@ -0,0 +1,304 @@
+package email_helper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 连接池默认空闲超时时间
+const defaultPoolIdleTimeout = 60 * time.Second
+
+// smtpPool 单个 SMTP 账号的连接池，保存已认证的空闲会话
+type smtpPool struct {
+	mu          sync.Mutex
+	config      EmailConfig
+	idle        []*smtpConn   // 空闲连接，后放入的在末尾
+	slots       chan struct{} // 限制同时存在的连接数（空闲 + 使用中）
+	idleTimeout time.Duration
+
+	// 统计
+	inUse    int
+	created  int64
+	reused   int64
+	evicted  int64
+	broken   int64
+	sent     int64
+	failed   int64
+	waitTime time.Duration
+}
+
+// PoolStats 连接池统计信息
+type PoolStats struct {
+	Account     string  `json:"account"`
+	Host        string  `json:"host"`
+	Port        int     `json:"port"`
+	Username    string  `json:"username"`
+	Size        int     `json:"size"`         // 最大连接数
+	IdleTimeout int     `json:"idle_timeout"` // 空闲超时（秒）
+	Idle        int     `json:"idle"`         // 空闲连接数
+	InUse       int     `json:"in_use"`       // 使用中的连接数
+	Created     int64   `json:"created"`      // 累计新建连接数
+	Reused      int64   `json:"reused"`       // 累计复用次数
+	Evicted     int64   `json:"evicted"`      // 累计因空闲超时或健康检查失败关闭的连接数
+	Broken      int64   `json:"broken"`       // 累计因发送出错关闭的连接数
+	Sent        int64   `json:"sent"`         // 累计发送成功数
+	Failed      int64   `json:"failed"`       // 累计发送失败数
+	AvgWaitMs   float64 `json:"avg_wait_ms"`  // 平均等待空闲连接的时间（毫秒）
+}
+
+var (
+	pools   = make(map[string]*smtpPool)
+	poolsMu sync.Mutex
+)
+
+// getPool 获取 SMTP 账号对应的连接池，不存在时创建
+// 账号名称、服务器、加密、认证和超时配置完全相同时共用一个连接池，修改配置后自动使用新的连接池
+func getPool(config EmailConfig) *smtpPool {
+	key := fmt.Sprintf("%s|%s|%d|%s|%s|%s|%t|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%s|%d|%d|%d|%d",
+		config.Account, config.Host, config.Port, config.Username, config.Password,
+		config.TLSMode, config.TLSSkipVerify, config.TLSCAFile, config.TLSMinVersion, config.TLSCertFile, config.TLSKeyFile,
+		config.AuthMechanism, config.OAuth2AccessToken, config.OAuth2TokenURL, config.OAuth2ClientID, config.OAuth2ClientSecret, config.OAuth2RefreshToken, config.OAuth2Scope,
+		config.ConnectTimeout, config.CommandTimeout, config.DataTimeout, config.PoolSize)
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	pool, ok := pools[key]
+	if !ok {
+		pool = &smtpPool{
+			config:      config,
+			slots:       make(chan struct{}, config.PoolSize),
+			idleTimeout: timeoutOrDefault(config.PoolIdleTimeout, defaultPoolIdleTimeout),
+		}
+		pools[key] = pool
+	}
+	return pool
+}
+
+// send 从连接池获取会话发送邮件，发送后 RSET 并放回连接池
+func (p *smtpPool) send(ctx context.Context, from string, to []string, msg []byte) ([]RecipientResult, error) {
+	start := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		results := newRecipientResults(to)
+		markRecipientsFailed(results, ctx.Err())
+		return results, ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	p.waitTime += time.Since(start)
+	p.inUse++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.inUse--
+		p.mu.Unlock()
+	}()
+
+	conn, err := p.acquire(ctx)
+	if err != nil {
+		p.count(&p.failed)
+		results := newRecipientResults(to)
+		markRecipientsFailed(results, err)
+		return results, err
+	}
+
+	results, err := conn.send(ctx, from, to, msg)
+	if err == nil {
+		p.count(&p.sent)
+	} else {
+		p.count(&p.failed)
+	}
+
+	// 服务器拒绝等业务错误不影响连接，网络错误、超时或取消后连接状态未知，直接关闭
+	if (err == nil || isReplyError(err)) && ctx.Err() == nil && conn.reset() == nil {
+		p.release(conn)
+	} else {
+		conn.close()
+		p.count(&p.broken)
+	}
+	return results, err
+}
+
+// acquire 获取可用的会话：优先复用空闲连接（NOOP 检查），没有时新建连接
+func (p *smtpPool) acquire(ctx context.Context) (*smtpConn, error) {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if time.Since(conn.lastUsedAt) > p.idleTimeout || conn.noop() != nil {
+			conn.close()
+			p.count(&p.evicted)
+			continue
+		}
+		p.count(&p.reused)
+		return conn, nil
+	}
+
+	conn, err := dialSMTP(ctx, p.config)
+	if err != nil {
+		return nil, err
+	}
+	p.count(&p.created)
+	return conn, nil
+}
+
+// release 将会话放回连接池，空闲连接已达到连接池大小时关闭
+func (p *smtpPool) release(conn *smtpConn) {
+	conn.lastUsedAt = time.Now()
+	p.mu.Lock()
+	if len(p.idle) < p.config.PoolSize {
+		p.idle = append(p.idle, conn)
+		conn = nil
+	}
+	p.mu.Unlock()
+
+	if conn != nil {
+		conn.quit()
+		conn.close()
+		p.count(&p.evicted)
+	}
+}
+
+// count 累加统计
+func (p *smtpPool) count(counter *int64) {
+	p.mu.Lock()
+	*counter++
+	p.mu.Unlock()
+}
+
+// evictIdle 关闭超过空闲时间的连接，其余连接逐个发送 NOOP 保活，失败的一并关闭
+// 检查中的连接占用一个连接名额并从空闲列表中取出，其他连接仍可被复用，连接总数不超过连接池大小
+func (p *smtpPool) evictIdle() {
+	p.mu.Lock()
+	var expired, alive []*smtpConn
+	for _, conn := range p.idle {
+		if time.Since(conn.lastUsedAt) > p.idleTimeout {
+			expired = append(expired, conn)
+		} else {
+			alive = append(alive, conn)
+		}
+	}
+	p.idle = append(p.idle[:0], alive...)
+	p.mu.Unlock()
+
+	for _, conn := range expired {
+		conn.quit()
+		conn.close()
+		p.count(&p.evicted)
+	}
+
+	for _, conn := range alive {
+		// 连接都在使用中时不检查，下次清理时再检查
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			return
+		}
+		if !p.take(conn) {
+			// 已被取出使用
+			<-p.slots
+			continue
+		}
+		// 保持原来的空闲时间，放回空闲列表最前面（最久未使用的位置）
+		if conn.noop() == nil {
+			p.mu.Lock()
+			if len(p.idle) < p.config.PoolSize {
+				p.idle = append([]*smtpConn{conn}, p.idle...)
+				conn = nil
+			}
+			p.mu.Unlock()
+		}
+		if conn != nil {
+			conn.close()
+			p.count(&p.evicted)
+		}
+		<-p.slots
+	}
+}
+
+// take 从空闲列表中取出指定的连接，连接已不在空闲列表中时返回 false
+func (p *smtpPool) take(conn *smtpConn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, item := range p.idle {
+		if item == conn {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// EvictIdleConnections 清理所有连接池中空闲超时或已失效的连接，由定时任务调用
+func EvictIdleConnections() {
+	poolsMu.Lock()
+	list := make([]*smtpPool, 0, len(pools))
+	for _, pool := range pools {
+		list = append(list, pool)
+	}
+	poolsMu.Unlock()
+
+	for _, pool := range list {
+		pool.evictIdle()
+	}
+}
+
+// GetPoolStats 获取所有连接池的统计信息
+func GetPoolStats() []PoolStats {
+	poolsMu.Lock()
+	list := make([]*smtpPool, 0, len(pools))
+	for _, pool := range pools {
+		list = append(list, pool)
+	}
+	poolsMu.Unlock()
+
+	stats := make([]PoolStats, 0, len(list))
+	for _, pool := range list {
+		pool.mu.Lock()
+		item := PoolStats{
+			Account:     pool.config.Account,
+			Host:        pool.config.Host,
+			Port:        pool.config.Port,
+			Username:    pool.config.Username,
+			Size:        pool.config.PoolSize,
+			IdleTimeout: int(pool.idleTimeout / time.Second),
+			Idle:        len(pool.idle),
+			InUse:       pool.inUse,
+			Created:     pool.created,
+			Reused:      pool.reused,
+			Evicted:     pool.evicted,
+			Broken:      pool.broken,
+			Sent:        pool.sent,
+			Failed:      pool.failed,
+		}
+		if total := pool.sent + pool.failed; total > 0 {
+			item.AvgWaitMs = float64(pool.waitTime.Microseconds()) / float64(total) / 1000
+		}
+		pool.mu.Unlock()
+		stats = append(stats, item)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Account != stats[j].Account {
+			return stats[i].Account < stats[j].Account
+		}
+		if stats[i].Host != stats[j].Host {
+			return stats[i].Host < stats[j].Host
+		}
+		if stats[i].Port != stats[j].Port {
+			return stats[i].Port < stats[j].Port
+		}
+		return stats[i].Username < stats[j].Username
+	})
+	return stats
+}
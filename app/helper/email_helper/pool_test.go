@@ -0,0 +1,60 @@
+package email_helper
+
+import (
+	"context"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestPoolReleaseAndEvict(t *testing.T) {
+	server := startFakeAuthServer(t, "LOGIN", func(t *testing.T, tp *textproto.Conn, args []string) bool {
+		tp.PrintfLine("334 VXNlcm5hbWU6")
+		readAuthLine(t, tp)
+		tp.PrintfLine("334 UGFzc3dvcmQ6")
+		readAuthLine(t, tp)
+		return true
+	})
+	config := server.config(AuthLogin)
+	config.PoolSize = 2
+	pool := &smtpPool{config: config, slots: make(chan struct{}, config.PoolSize), idleTimeout: time.Minute}
+
+	var conns []*smtpConn
+	for i := 0; i < 3; i++ {
+		conn, err := dialSMTP(context.Background(), config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	// 空闲连接不超过连接池大小，多出的连接关闭
+	for _, conn := range conns {
+		pool.release(conn)
+	}
+	if len(pool.idle) != 2 || pool.evicted != 1 {
+		t.Fatalf("空闲连接数 = %d，关闭数 = %d，期望 2 和 1", len(pool.idle), pool.evicted)
+	}
+
+	// 连接都在使用中时只关闭超时的连接，不发送 NOOP
+	pool.idle[0].lastUsedAt = time.Now().Add(-time.Hour)
+	pool.slots <- struct{}{}
+	pool.slots <- struct{}{}
+	pool.evictIdle()
+	if len(pool.idle) != 1 || pool.evicted != 2 {
+		t.Fatalf("空闲连接数 = %d，关闭数 = %d，期望 1 和 2", len(pool.idle), pool.evicted)
+	}
+	<-pool.slots
+	<-pool.slots
+
+	// 空闲的连接 NOOP 检查后放回
+	pool.evictIdle()
+	if len(pool.idle) != 1 || pool.evicted != 2 {
+		t.Fatalf("空闲连接数 = %d，关闭数 = %d，期望 1 和 2", len(pool.idle), pool.evicted)
+	}
+	if conn, err := pool.acquire(context.Background()); err != nil || pool.reused != 1 {
+		t.Fatalf("应复用空闲连接: %v", err)
+	} else {
+		conn.quit()
+		conn.close()
+	}
+}
@@ -0,0 +1,71 @@
+package email_helper
+
+import (
+	"net/textproto"
+)
+
+// 收件人投递状态
+const (
+	RecipientStatusSent     = "sent"     // 服务器已接收
+	RecipientStatusRejected = "rejected" // 服务器拒绝该收件人（RCPT TO 返回错误）
+	RecipientStatusFailed   = "failed"   // 收件人已被接受，但邮件内容传输失败或会话中断
+	RecipientStatusSkipped  = "skipped"  // 在抑制列表中，未投递
+)
+
+// 收件人类型
+const (
+	RecipientTypeTo  = "to"
+	RecipientTypeCc  = "cc"
+	RecipientTypeBcc = "bcc"
+)
+
+// RecipientResult 单个收件人的投递结果
+type RecipientResult struct {
+	Address string `json:"address"` // 邮箱地址
+	Type    string `json:"type"`    // 收件人类型：to、cc、bcc
+	Status  string `json:"status"`  // 投递状态：sent、rejected、failed、skipped
+	Code    int    `json:"code"`    // SMTP 响应码
+	Message string `json:"message"` // SMTP 响应内容或错误信息
+}
+
+// newRecipientResults 初始化收件人投递结果，默认为失败
+func newRecipientResults(to []string) []RecipientResult {
+	results := make([]RecipientResult, len(to))
+	for i, address := range to {
+		results[i] = RecipientResult{Address: address, Status: RecipientStatusFailed}
+	}
+	return results
+}
+
+// markRecipientsFailed 将尚未确定结果和已接受的收件人标记为失败
+func markRecipientsFailed(results []RecipientResult, err error) {
+	for i := range results {
+		if results[i].Status == RecipientStatusRejected {
+			continue
+		}
+		results[i].Status = RecipientStatusFailed
+		results[i].Code = replyCode(err)
+		results[i].Message = err.Error()
+	}
+}
+
+// rejectedRecipientError 所有收件人都被拒绝时返回的错误，优先返回 4xx 临时错误以便故障转移
+func rejectedRecipientError(errs []*textproto.Error) error {
+	for _, err := range errs {
+		if err.Code < 500 {
+			return err
+		}
+	}
+	return errs[0]
+}
+
+// CountRecipients 统计指定状态的收件人数量
+func CountRecipients(results []RecipientResult, status string) int {
+	count := 0
+	for _, result := range results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
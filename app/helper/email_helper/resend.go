@@ -0,0 +1,162 @@
+package email_helper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/model"
+	"net/mail"
+	"strings"
+)
+
+// resendRequest 发送记录的请求参数中重发需要的字段
+type resendRequest struct {
+	TextBody    string      `json:"text_body"`
+	Charset     string      `json:"charset"`
+	Unsubscribe interface{} `json:"unsubscribe"`
+	Calendar    interface{} `json:"calendar"`
+}
+
+// NewResendMessage 根据发送记录还原邮件内容，异步发送的记录从发送队列读取完整邮件（含附件）
+// 同步发送的记录不保存附件内容，包含附件、内联资源或日历邀请时无法重发
+func NewResendMessage(emailLog model.EmailLog) (EmailMessage, error) {
+	var message EmailMessage
+	if emailLog.OutboxId > 0 {
+		var outbox model.EmailOutbox
+		if err := db_helper.Db().Where("id = ?", emailLog.OutboxId).First(&outbox).Error; err == nil {
+			if err := json.Unmarshal([]byte(outbox.Message), &message); err != nil {
+				return message, fmt.Errorf("邮件内容解析失败: %v", err)
+			}
+			message.MessageID = ""
+			return message, nil
+		}
+	}
+
+	if emailLog.Attachments != "" {
+		return message, errors.New("原邮件包含附件或内联资源，附件内容未保存，无法重发")
+	}
+	var request resendRequest
+	if emailLog.RequestData != "" {
+		json.Unmarshal([]byte(emailLog.RequestData), &request)
+	}
+	if request.Calendar != nil {
+		return message, errors.New("原邮件包含日历邀请，无法重发")
+	}
+
+	var err error
+	parse := func(s string, label string) []mail.Address {
+		if err != nil || s == "" {
+			return nil
+		}
+		var addresses []mail.Address
+		if addresses, err = ParseAddressList(s); err != nil {
+			err = fmt.Errorf("%s解析失败: %v", label, err)
+		}
+		return addresses
+	}
+	message = EmailMessage{
+		To:        parse(emailLog.ToEmail, "收件人"),
+		Cc:        parse(emailLog.CcEmail, "抄送"),
+		Bcc:       parse(emailLog.BccEmail, "密送"),
+		ReplyTo:   parse(emailLog.ReplyTo, "回复地址"),
+		Priority:  int(emailLog.Priority),
+		Subject:   emailLog.Subject,
+		Body:      emailLog.Body,
+		TextBody:  request.TextBody,
+		IsHTML:    emailLog.IsHTML == 1,
+		Charset:   request.Charset,
+		ThreadKey: emailLog.ThreadKey,
+		InReplyTo: emailLog.InReplyTo,
+		Category:  emailLog.Category,
+	}
+	if err != nil {
+		return message, err
+	}
+	if emailLog.References != "" {
+		message.References = strings.Fields(emailLog.References)
+	}
+	if emailLog.Headers != "" {
+		if err := json.Unmarshal([]byte(emailLog.Headers), &message.Headers); err != nil {
+			return message, fmt.Errorf("自定义信头解析失败: %v", err)
+		}
+	}
+	// 启用了退订的邮件重新生成退订链接
+	if unsubscribe := fmt.Sprintf("%v", request.Unsubscribe); (unsubscribe == "true" || unsubscribe == "1") && len(message.To) == 1 {
+		if message.UnsubscribeURL, err = NewUnsubscribeURL(message.To[0].Address, message.Category); err != nil {
+			return message, err
+		}
+	}
+	return message, nil
+}
+
+// ResendEmailLog 立即重发一条发送记录，account 为空时使用原记录的账号
+// 新的发送记录的 resend_of 为原始记录ID（重发记录再次重发时仍指向最初的记录）
+func ResendEmailLog(ctx context.Context, emailLog model.EmailLog, account string, requestIP string) (model.EmailLog, EmailResult, error) {
+	message, configs, skipped, err := prepareResend(emailLog, account)
+	if err != nil {
+		return model.EmailLog{}, EmailResult{}, err
+	}
+
+	result := SendEmailWithFailover(ctx, configs, message)
+	result.Recipients = append(result.Recipients, skipped...)
+
+	var requestData interface{}
+	if emailLog.RequestData != "" {
+		requestData = json.RawMessage(emailLog.RequestData)
+	}
+	newLog := NewEmailLog(requestIP, message, configs[0], result, requestData)
+	newLog.ResendOf = resendRoot(emailLog)
+	SaveEmailLog(&newLog, result)
+	if result.Success {
+		SaveThreadMessage(message, result.MessageID, 0)
+	}
+	return newLog, result, nil
+}
+
+// EnqueueResend 将发送记录重新写入发送队列，由工作协程投递
+func EnqueueResend(emailLog model.EmailLog, account string, requestIP string) (model.EmailOutbox, error) {
+	message, configs, _, err := prepareResend(emailLog, account)
+	if err != nil {
+		return model.EmailOutbox{}, err
+	}
+	var requestData interface{}
+	if emailLog.RequestData != "" {
+		requestData = json.RawMessage(emailLog.RequestData)
+	}
+	return EnqueueEmail(requestIP, configs[0].Account, message, EnqueueOptions{
+		RequestData: requestData,
+		ResendOf:    resendRoot(emailLog),
+	})
+}
+
+// prepareResend 还原邮件内容、获取发送账号，并重新检查抑制列表
+func prepareResend(emailLog model.EmailLog, account string) (EmailMessage, []EmailConfig, []RecipientResult, error) {
+	message, err := NewResendMessage(emailLog)
+	if err != nil {
+		return message, nil, nil, err
+	}
+	if account == "" {
+		account = emailLog.Account
+	}
+	configs, err := GetFailoverChain(account)
+	if err != nil {
+		return message, nil, nil, err
+	}
+
+	var skipped []RecipientResult
+	message.To, message.Cc, message.Bcc, skipped = FilterSuppressed(message.Category, message.To, message.Cc, message.Bcc)
+	if len(message.To) == 0 {
+		return message, nil, skipped, errors.New("收件人均在抑制列表中")
+	}
+	return message, configs, skipped, nil
+}
+
+// resendRoot 重发记录关联的原始记录ID
+func resendRoot(emailLog model.EmailLog) uint {
+	if emailLog.ResendOf > 0 {
+		return emailLog.ResendOf
+	}
+	return emailLog.Id
+}
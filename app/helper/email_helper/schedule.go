@@ -0,0 +1,113 @@
+package email_helper
+
+import (
+	"errors"
+	"fmt"
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/helper/log_helper"
+	"gin_base/app/helper/type_helper"
+	"gin_base/app/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// 预约时间允许早于当前时间的误差，早于该范围视为格式或时区错误
+	scheduleClockSkew = time.Minute
+	// 最多预约多久之后发送
+	maxScheduleAhead = 366 * 24 * time.Hour
+)
+
+// 预约时间支持的格式，不带时区时按服务器时区解析
+var sendAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// ParseSendAt 解析预约发送时间，支持 RFC 3339（如 2030-01-02T09:00:00+08:00）和 Unix 时间戳（秒）
+// 为空时返回零值（立即发送），返回的时间统一转为服务器时区，保证数据库中按时间比较的结果正确
+func ParseSendAt(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	var sendAt time.Time
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		sendAt = time.Unix(timestamp, 0)
+	} else {
+		for _, layout := range sendAtLayouts {
+			if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+				sendAt = t
+				break
+			}
+		}
+		if sendAt.IsZero() {
+			return time.Time{}, fmt.Errorf("预约发送时间格式错误: %s", value)
+		}
+	}
+	if sendAt.Before(now.Add(-scheduleClockSkew)) {
+		return time.Time{}, errors.New("预约发送时间不能早于当前时间")
+	}
+	if sendAt.After(now.Add(maxScheduleAhead)) {
+		return time.Time{}, errors.New("预约发送时间不能超过一年")
+	}
+	return sendAt.In(time.Local), nil
+}
+
+// ReleaseScheduledEmails 将到达预约时间的邮件转为待投递并唤醒调度协程，由定时任务调用
+func ReleaseScheduledEmails() {
+	result := db_helper.Db().Model(&model.EmailOutbox{}).
+		Where("status = ? AND send_at <= ?", OutboxStatusScheduled, time.Now()).
+		Updates(map[string]interface{}{"status": OutboxStatusPending})
+	if result.Error != nil {
+		log_helper.Error(fmt.Sprintf("释放预约邮件失败: %v", result.Error))
+		return
+	}
+	if result.RowsAffected > 0 {
+		NotifyOutbox()
+	}
+}
+
+// RescheduleOutbox 修改预约发送时间，只能修改尚未到达预约时间的邮件
+func RescheduleOutbox(id uint, sendAt time.Time) (model.EmailOutbox, error) {
+	outbox, err := GetOutbox(id, "")
+	if err != nil {
+		return outbox, err
+	}
+	scheduledAt := type_helper.Time(sendAt)
+	result := db_helper.Db().Model(&model.EmailOutbox{}).
+		Where("id = ? AND status = ?", id, OutboxStatusScheduled).
+		Updates(map[string]interface{}{"send_at": scheduledAt, "next_attempt_at": scheduledAt})
+	if result.Error != nil {
+		return outbox, fmt.Errorf("修改预约时间失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return outbox, errors.New("邮件不是预约状态，无法修改预约时间")
+	}
+	return GetOutbox(id, "")
+}
+
+// CancelOutbox 取消预约或待投递（含等待重试）的邮件，投递中和已完成的邮件不能取消
+func CancelOutbox(id uint) (model.EmailOutbox, error) {
+	outbox, err := GetOutbox(id, "")
+	if err != nil {
+		return outbox, err
+	}
+	result := db_helper.Db().Model(&model.EmailOutbox{}).
+		Where("id = ? AND status IN ?", id, []string{OutboxStatusScheduled, OutboxStatusPending}).
+		Updates(map[string]interface{}{"status": OutboxStatusCanceled, "canceled_at": type_helper.Time(time.Now())})
+	if result.Error != nil {
+		return outbox, fmt.Errorf("取消失败: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if outbox.Status == OutboxStatusCanceled {
+			return outbox, errors.New("邮件已取消")
+		}
+		return outbox, errors.New("邮件正在投递或已完成，无法取消")
+	}
+	return GetOutbox(id, "")
+}
@@ -0,0 +1,324 @@
+package email_helper
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 默认超时时间
+const (
+	defaultConnectTimeout = 10 * time.Second
+	defaultCommandTimeout = 30 * time.Second
+	defaultDataTimeout    = 120 * time.Second
+)
+
+// 发送失败的错误类型
+const (
+	ErrorTypeTimeout    = "timeout"    // 连接、命令或数据传输超时
+	ErrorTypeCanceled   = "canceled"   // 请求被取消（如 HTTP 客户端断开）
+	ErrorTypeConnection = "connection" // 无法连接、TLS 握手失败或连接中断
+	ErrorTypeTemporary  = "temporary"  // 服务器返回 4xx 临时错误
+	ErrorTypePermanent  = "permanent"  // 服务器返回 5xx 永久错误
+)
+
+// connectError 建立会话阶段（连接、TLS、认证前）的错误
+type connectError struct {
+	err error
+}
+
+func (e *connectError) Error() string { return e.err.Error() }
+func (e *connectError) Unwrap() error { return e.err }
+
+// smtpConn 已建立并完成认证的 SMTP 会话
+type smtpConn struct {
+	client         *smtp.Client
+	rawConn        net.Conn // 底层 TCP 连接，TLS 连接都包装在它之上，用于设置超时和强制关闭
+	commandTimeout time.Duration
+	dataTimeout    time.Duration
+	createdAt      time.Time
+	lastUsedAt     time.Time
+}
+
+// sendMail 通过 SMTP 投递邮件，返回每个收件人的投递结果
+// 配置了连接池时复用已认证的会话，否则每次新建连接并在发送后断开
+func sendMail(ctx context.Context, config EmailConfig, from string, to []string, msg []byte) ([]RecipientResult, error) {
+	if config.PoolSize > 0 {
+		return getPool(config).send(ctx, from, to, msg)
+	}
+
+	conn, err := dialSMTP(ctx, config)
+	if err != nil {
+		results := newRecipientResults(to)
+		markRecipientsFailed(results, err)
+		return results, err
+	}
+	defer conn.close()
+	results, err := conn.send(ctx, from, to, msg)
+	if err != nil {
+		return results, err
+	}
+	// 邮件已被服务器接收，QUIT 失败不影响结果
+	conn.quit()
+	return results, nil
+}
+
+// dialSMTP 建立 SMTP 会话：连接、按 TLSMode 加密、按 AuthMechanism 认证
+// 要求加密的模式下无法加密时直接报错，不会降级为明文；服务器响应以外的错误都视为连接错误
+func dialSMTP(ctx context.Context, config EmailConfig) (conn *smtpConn, err error) {
+	defer func() {
+		if err != nil && !isReplyError(err) {
+			err = &connectError{err: err}
+		}
+	}()
+
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	connectTimeout := timeoutOrDefault(config.ConnectTimeout, defaultConnectTimeout)
+
+	mode, err := ResolveTLSMode(config)
+	if err != nil {
+		return nil, err
+	}
+	var tlsConfig *tls.Config
+	if mode != TLSModeNone {
+		if tlsConfig, err = buildTLSConfig(config); err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	rawConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn = &smtpConn{
+		rawConn:        rawConn,
+		commandTimeout: timeoutOrDefault(config.CommandTimeout, defaultCommandTimeout),
+		dataTimeout:    timeoutOrDefault(config.DataTimeout, defaultDataTimeout),
+		createdAt:      time.Now(),
+		lastUsedAt:     time.Now(),
+	}
+	stop := conn.watch(ctx)
+	defer stop()
+
+	var netConn net.Conn = rawConn
+	conn.setDeadline(connectTimeout)
+	if mode == TLSModeImplicit {
+		tlsConn := tls.Client(rawConn, tlsConfig)
+		if err = tlsConn.Handshake(); err != nil {
+			rawConn.Close()
+			return nil, fmt.Errorf("TLS 连接失败: %w", err)
+		}
+		netConn = tlsConn
+	}
+
+	// 读取服务器欢迎信息
+	conn.client, err = smtp.NewClient(netConn, config.Host)
+	if err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+
+	if err = conn.handshake(ctx, config, mode, tlsConfig, connectTimeout); err != nil {
+		conn.close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// handshake 按 TLSMode 升级 STARTTLS 并按 AuthMechanism 认证
+func (c *smtpConn) handshake(ctx context.Context, config EmailConfig, mode string, tlsConfig *tls.Config, connectTimeout time.Duration) error {
+	c.setDeadline(c.commandTimeout)
+	if mode == TLSModeStartTLS || mode == TLSModeOpportunistic {
+		ok, _ := c.client.Extension("STARTTLS")
+		if ok {
+			// 服务器声明支持 STARTTLS 后握手失败属于异常，opportunistic 模式下也不降级为明文
+			c.setDeadline(connectTimeout)
+			if err := c.client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS 握手失败: %w", err)
+			}
+		} else if mode == TLSModeStartTLS {
+			return errors.New("SMTP 服务器不支持 STARTTLS，当前 TLS 模式要求加密连接")
+		}
+	}
+
+	return c.authenticate(ctx, config)
+}
+
+// send 在当前会话中投递一封邮件，连接、每条命令和 DATA 阶段分别受超时限制，ctx 取消时立即关闭连接
+// 部分收件人被拒绝时继续投递给其他收件人，全部被拒绝时返回错误
+func (c *smtpConn) send(ctx context.Context, from string, to []string, msg []byte) (results []RecipientResult, err error) {
+	stop := c.watch(ctx)
+	defer stop()
+	c.lastUsedAt = time.Now()
+
+	results = newRecipientResults(to)
+	defer func() {
+		if err != nil {
+			markRecipientsFailed(results, err)
+		}
+	}()
+
+	// 国际化邮箱地址（本地部分包含非 ASCII 字符）需要服务器支持 SMTPUTF8，Mail() 会自动带上 SMTPUTF8 参数
+	if needSMTPUTF8(append([]string{from}, to...)...) {
+		if ok, _ := c.client.Extension("SMTPUTF8"); !ok {
+			return results, errors.New("SMTP 服务器不支持 SMTPUTF8，无法投递包含非 ASCII 字符的邮箱地址")
+		}
+	}
+
+	c.setDeadline(c.commandTimeout)
+	if err = c.client.Mail(from); err != nil {
+		return results, err
+	}
+
+	var rejected []*textproto.Error
+	for i, addr := range to {
+		c.setDeadline(c.commandTimeout)
+		if err = c.client.Rcpt(addr); err != nil {
+			var protoErr *textproto.Error
+			if !errors.As(err, &protoErr) {
+				return results, err
+			}
+			// 服务器拒绝该收件人，记录后继续下一个
+			results[i].Status = RecipientStatusRejected
+			results[i].Code = protoErr.Code
+			results[i].Message = protoErr.Msg
+			rejected = append(rejected, protoErr)
+			err = nil
+			continue
+		}
+		results[i].Status = ""
+	}
+	if len(rejected) == len(to) {
+		return results, rejectedRecipientError(rejected)
+	}
+
+	c.setDeadline(c.commandTimeout)
+	w, err := c.client.Data()
+	if err != nil {
+		return results, err
+	}
+
+	c.setDeadline(c.dataTimeout)
+	if _, err = w.Write(msg); err != nil {
+		return results, err
+	}
+	if err = w.Close(); err != nil {
+		return results, err
+	}
+
+	for i := range results {
+		if results[i].Status != RecipientStatusRejected {
+			results[i].Status = RecipientStatusSent
+			results[i].Code = 250
+		}
+	}
+	return results, nil
+}
+
+// watch ctx 取消时关闭连接，使阻塞中的读写立即返回
+// 调用返回的函数停止监听，并等待监听协程退出，避免连接放回连接池后被误关闭
+func (c *smtpConn) watch(ctx context.Context) func() {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			c.rawConn.Close()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// setDeadline 设置下一步操作的超时时间
+func (c *smtpConn) setDeadline(timeout time.Duration) {
+	c.rawConn.SetDeadline(time.Now().Add(timeout))
+}
+
+// reset 发送 RSET 清除当前事务，连接可继续投递下一封邮件
+func (c *smtpConn) reset() error {
+	c.setDeadline(c.commandTimeout)
+	return c.client.Reset()
+}
+
+// noop 发送 NOOP 检查连接是否可用
+func (c *smtpConn) noop() error {
+	c.setDeadline(c.commandTimeout)
+	return c.client.Noop()
+}
+
+// quit 发送 QUIT 正常结束会话
+func (c *smtpConn) quit() error {
+	c.setDeadline(c.commandTimeout)
+	return c.client.Quit()
+}
+
+// close 关闭连接
+func (c *smtpConn) close() {
+	if c.client != nil {
+		c.client.Close()
+	} else {
+		c.rawConn.Close()
+	}
+}
+
+// timeoutOrDefault 未配置超时时间时使用默认值
+func timeoutOrDefault(timeout time.Duration, defaultTimeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return defaultTimeout
+	}
+	return timeout
+}
+
+// isReplyError 判断是否为 SMTP 服务器的错误响应（如收件人被拒绝），此时连接本身仍可用
+func isReplyError(err error) bool {
+	return replyCode(err) > 0
+}
+
+// replyCode 获取 SMTP 服务器错误响应的状态码，非服务器响应的错误返回 0
+func replyCode(err error) int {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code
+	}
+	return 0
+}
+
+// sendErrorType 判断发送失败的错误类型，ctx 取消或超时优先于网络错误
+func sendErrorType(ctx context.Context, err error) string {
+	switch ctx.Err() {
+	case context.Canceled:
+		return ErrorTypeCanceled
+	case context.DeadlineExceeded:
+		return ErrorTypeTimeout
+	}
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorTypeTimeout
+	}
+	if code := replyCode(err); code >= 500 {
+		return ErrorTypePermanent
+	} else if code >= 400 {
+		return ErrorTypeTemporary
+	}
+	var connErr *connectError
+	var opErr *net.OpError
+	if errors.As(err, &connErr) || errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return ErrorTypeConnection
+	}
+	return ""
+}
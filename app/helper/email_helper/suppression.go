@@ -0,0 +1,275 @@
+package email_helper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/helper/type_helper"
+	"gin_base/app/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 抑制原因
+const (
+	SuppressionReasonBounce      = "bounce"      // 硬退信
+	SuppressionReasonComplaint   = "complaint"   // 投诉（标记为垃圾邮件）
+	SuppressionReasonUnsubscribe = "unsubscribe" // 退订
+	SuppressionReasonManual      = "manual"      // 手动添加
+)
+
+// 抑制来源
+const (
+	SuppressionSourceAPI    = "api"    // 接口添加
+	SuppressionSourceImport = "import" // 批量导入
+)
+
+const (
+	// 单次导入的最大条数
+	maxSuppressionImport = 10000
+	// 每批写入数据库的条数
+	suppressionBatchSize = 500
+	// 来源、备注、退订请求IP和 User-Agent 的最大长度
+	maxSuppressionSourceLength    = 100
+	maxSuppressionNoteLength      = 500
+	maxSuppressionRequestIPLength = 50
+	maxSuppressionUserAgentLength = 500
+)
+
+// 抑制原因说明
+var suppressionReasonText = map[string]string{
+	SuppressionReasonBounce:      "退信",
+	SuppressionReasonComplaint:   "投诉",
+	SuppressionReasonUnsubscribe: "退订",
+	SuppressionReasonManual:      "手动添加",
+}
+
+// 过期时间支持的格式，只有日期时当天结束后过期
+var suppressionExpiryLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// ValidateSuppressionReason 校验抑制原因
+func ValidateSuppressionReason(reason string) error {
+	if _, ok := suppressionReasonText[reason]; !ok {
+		return fmt.Errorf("抑制原因只能为 bounce、complaint、unsubscribe 或 manual: %s", reason)
+	}
+	return nil
+}
+
+// ParseSuppressionExpiry 解析过期时间，为空时返回 nil（永久有效）
+func ParseSuppressionExpiry(value string) (*type_helper.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		expiresAt := type_helper.Time(t.AddDate(0, 0, 1))
+		return &expiresAt, nil
+	}
+	for _, layout := range suppressionExpiryLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			expiresAt := type_helper.Time(t)
+			return &expiresAt, nil
+		}
+	}
+	return nil, fmt.Errorf("过期时间格式错误: %s", value)
+}
+
+// NewSuppression 校验并创建抑制记录，邮箱地址统一转为小写
+func NewSuppression(email string, category string, reason string, source string, note string, expiresAt *type_helper.Time) (model.EmailSuppression, error) {
+	address, err := NewAddress("", email)
+	if err != nil {
+		return model.EmailSuppression{}, err
+	}
+	category = strings.TrimSpace(category)
+	if err := ValidateCategory(category); err != nil {
+		return model.EmailSuppression{}, err
+	}
+	reason = strings.ToLower(strings.TrimSpace(reason))
+	if reason == "" {
+		reason = SuppressionReasonManual
+	}
+	if err := ValidateSuppressionReason(reason); err != nil {
+		return model.EmailSuppression{}, err
+	}
+	return model.EmailSuppression{
+		Email:     strings.ToLower(address.Address),
+		Category:  category,
+		Reason:    reason,
+		Source:    truncateRunes(strings.TrimSpace(source), maxSuppressionSourceLength),
+		Note:      truncateRunes(strings.TrimSpace(note), maxSuppressionNoteLength),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// SaveSuppressions 保存抑制记录，同一邮箱和作用范围已存在时更新原因、来源、备注和过期时间
+// 退订时记录的请求IP和 User-Agent 只由退订更新，手动添加和导入时保留
+func SaveSuppressions(suppressions []model.EmailSuppression) error {
+	return saveSuppressions(suppressions, "reason", "source", "note", "expires_at", "updated_at")
+}
+
+// saveSuppressions 保存抑制记录，同一邮箱和作用范围已存在时更新 columns
+func saveSuppressions(suppressions []model.EmailSuppression, columns ...string) error {
+	if len(suppressions) == 0 {
+		return nil
+	}
+	return db_helper.Db().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "email"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).CreateInBatches(&suppressions, suppressionBatchSize).Error
+}
+
+// ParseSuppressionCSV 解析导入的 CSV，每行为 email,reason,category,expires_at,note，只有 email 必填
+// 第一行为 email 表头时跳过，列为空时使用 defaults 中的值；返回有效记录和每行的错误
+func ParseSuppressionCSV(data []byte, defaults model.EmailSuppression) ([]model.EmailSuppression, []string) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var suppressions []model.EmailSuppression
+	var errs []string
+	index := make(map[string]int)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("第 %d 行：CSV 格式错误", line))
+			break
+		}
+		column := func(i int) string {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		email := column(0)
+		if email == "" || (line == 1 && strings.EqualFold(email, "email")) {
+			continue
+		}
+		if len(suppressions) >= maxSuppressionImport {
+			errs = append(errs, fmt.Sprintf("单次最多导入 %d 条，第 %d 行及之后未导入", maxSuppressionImport, line))
+			break
+		}
+
+		reason, category, note := column(1), column(2), column(4)
+		if reason == "" {
+			reason = defaults.Reason
+		}
+		if category == "" {
+			category = defaults.Category
+		}
+		if note == "" {
+			note = defaults.Note
+		}
+		expiresAt := defaults.ExpiresAt
+		if column(3) != "" {
+			if expiresAt, err = ParseSuppressionExpiry(column(3)); err != nil {
+				errs = append(errs, fmt.Sprintf("第 %d 行：%s", line, err.Error()))
+				continue
+			}
+		}
+		suppression, err := NewSuppression(email, category, reason, defaults.Source, note, expiresAt)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("第 %d 行：%s", line, err.Error()))
+			continue
+		}
+
+		// 文件内重复的邮箱和作用范围以最后一行为准
+		key := suppression.Email + "\n" + suppression.Category
+		if i, ok := index[key]; ok {
+			suppressions[i] = suppression
+			continue
+		}
+		index[key] = len(suppressions)
+		suppressions = append(suppressions, suppression)
+	}
+	return suppressions, errs
+}
+
+// FilterSuppressed 去掉抑制列表中的收件人，返回剩余的收件人和被跳过的收件人
+// 全局记录对所有邮件生效，分类记录只对该分类的邮件生效，已过期的记录不生效
+func FilterSuppressed(category string, to []mail.Address, cc []mail.Address, bcc []mail.Address) ([]mail.Address, []mail.Address, []mail.Address, []RecipientResult) {
+	var addresses []string
+	for _, list := range [][]mail.Address{to, cc, bcc} {
+		for _, address := range list {
+			addresses = append(addresses, strings.ToLower(address.Address))
+		}
+	}
+	if len(addresses) == 0 {
+		return to, cc, bcc, nil
+	}
+
+	var suppressions []struct {
+		Email    string
+		Category string
+		Reason   string
+	}
+	activeSuppressions(db_helper.Db().Model(&model.EmailSuppression{})).
+		Select("email, category, reason").
+		Where("email IN ?", addresses).
+		Where("category = '' OR category = ?", category).
+		Order("category").
+		Find(&suppressions)
+	if len(suppressions) == 0 {
+		return to, cc, bcc, nil
+	}
+	// 同时存在全局和分类记录时，按 category 排序后全局记录在前，以全局记录的原因为准
+	reasons := make(map[string]string)
+	for _, suppression := range suppressions {
+		if _, ok := reasons[suppression.Email]; !ok {
+			reasons[suppression.Email] = suppression.Reason
+		}
+	}
+
+	var skipped []RecipientResult
+	filter := func(list []mail.Address, recipientType string) []mail.Address {
+		var result []mail.Address
+		for _, address := range list {
+			if reason, ok := reasons[strings.ToLower(address.Address)]; ok {
+				skipped = append(skipped, RecipientResult{
+					Address: address.Address,
+					Type:    recipientType,
+					Status:  RecipientStatusSkipped,
+					Message: "在抑制列表中：" + SuppressionReasonText(reason),
+				})
+				continue
+			}
+			result = append(result, address)
+		}
+		return result
+	}
+	return filter(to, RecipientTypeTo), filter(cc, RecipientTypeCc), filter(bcc, RecipientTypeBcc), skipped
+}
+
+// SuppressionReasonText 抑制原因说明
+func SuppressionReasonText(reason string) string {
+	if text, ok := suppressionReasonText[reason]; ok {
+		return text
+	}
+	return reason
+}
+
+// activeSuppressions 只查询未过期的抑制记录
+func activeSuppressions(db *gorm.DB) *gorm.DB {
+	return db.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+// truncateRunes 按字符截断字符串
+func truncateRunes(s string, maxLength int) string {
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+	return string([]rune(s)[:maxLength])
+}
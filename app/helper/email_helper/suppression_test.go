@@ -0,0 +1,65 @@
+package email_helper
+
+import (
+	"gin_base/app/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSuppressionCSV(t *testing.T) {
+	data := "\xef\xbb\xbfemail,reason,category,expires_at,note\n" +
+		"Bounce@Example.COM\n" +
+		"a@example.com,complaint,promo,2030-01-02 03:04,投诉\n" +
+		"not-an-address,bounce\n" +
+		"b@example.com,spam\n" +
+		"c@example.com,,,tomorrow\n" +
+		"\n" +
+		"a@example.com,manual,promo\n"
+	suppressions, errs := ParseSuppressionCSV([]byte(data), model.EmailSuppression{
+		Reason: SuppressionReasonBounce,
+		Source: SuppressionSourceImport,
+	})
+
+	if len(suppressions) != 2 {
+		t.Fatalf("有效记录数 = %d，期望 2: %+v", len(suppressions), suppressions)
+	}
+	first := suppressions[0]
+	if first.Email != "bounce@example.com" || first.Reason != SuppressionReasonBounce || first.Category != "" || first.Source != SuppressionSourceImport {
+		t.Errorf("未填写的列应使用默认值: %+v", first)
+	}
+	// 重复的邮箱和作用范围以最后一行为准
+	second := suppressions[1]
+	if second.Email != "a@example.com" || second.Reason != SuppressionReasonManual || second.ExpiresAt != nil || second.Note != "" {
+		t.Errorf("重复行应以最后一行为准: %+v", second)
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("错误数 = %d，期望 3: %v", len(errs), errs)
+	}
+	for i, line := range []string{"第 4 行", "第 5 行", "第 6 行"} {
+		if !strings.HasPrefix(errs[i], line) {
+			t.Errorf("错误 %q 应以 %q 开头", errs[i], line)
+		}
+	}
+}
+
+func TestParseSuppressionExpiry(t *testing.T) {
+	expiresAt, err := ParseSuppressionExpiry("")
+	if err != nil || expiresAt != nil {
+		t.Errorf("为空时应永久有效: %v, %v", expiresAt, err)
+	}
+
+	// 只有日期时当天结束后过期
+	expiresAt, err = ParseSuppressionExpiry("2030-01-02")
+	if err != nil || !time.Time(*expiresAt).Equal(time.Date(2030, 1, 3, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("2030-01-02 解析结果 = %v, %v", expiresAt, err)
+	}
+	expiresAt, err = ParseSuppressionExpiry("2030-01-02T03:04:05+08:00")
+	if err != nil || !time.Time(*expiresAt).Equal(time.Date(2030, 1, 1, 19, 4, 5, 0, time.UTC)) {
+		t.Errorf("RFC 3339 解析结果 = %v, %v", expiresAt, err)
+	}
+	if _, err := ParseSuppressionExpiry("next week"); err == nil {
+		t.Error("格式错误时应返回错误")
+	}
+}
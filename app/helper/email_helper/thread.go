@@ -0,0 +1,106 @@
+package email_helper
+
+import (
+	"fmt"
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/helper/log_helper"
+	"gin_base/app/model"
+	"gorm.io/gorm"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// thread_key 的最大长度
+	maxThreadKeyLength = 200
+	// References 最多保留的 Message-ID 数量，超出时保留第一封和最近的邮件（RFC 5322 3.6.4）
+	maxReferences = 20
+)
+
+// ValidateThreadKey 校验会话标识
+func ValidateThreadKey(threadKey string) error {
+	if utf8.RuneCountInString(threadKey) > maxThreadKeyLength {
+		return fmt.Errorf("thread_key 不能超过 %d 个字符", maxThreadKeyLength)
+	}
+	return nil
+}
+
+// ValidateMessageID 校验 Message-ID 格式（可带尖括号），为空时不校验
+func ValidateMessageID(messageID string) error {
+	messageID = NormalizeMessageID(messageID)
+	if messageID == "" {
+		return nil
+	}
+	if len(messageID) > 250 || !strings.Contains(messageID, "@") || strings.ContainsAny(messageID, " \t\r\n<>\"") {
+		return fmt.Errorf("Message-ID 格式错误: %s", messageID)
+	}
+	return nil
+}
+
+// NormalizeMessageID 去掉 Message-ID 两侧的空白和尖括号
+func NormalizeMessageID(messageID string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(messageID), "<"), ">")
+}
+
+// SaveThreadMessage 记录会话中的邮件，同步发送成功或入队时调用，同一会话紧接着发送的邮件可以立即找到这封邮件
+// outboxId 为发送队列ID，同步发送时为 0；没有 thread_key 的邮件不记录
+func SaveThreadMessage(message EmailMessage, messageID string, outboxId uint) {
+	messageID = NormalizeMessageID(messageID)
+	if message.ThreadKey == "" || messageID == "" {
+		return
+	}
+	thread := model.EmailThread{
+		ThreadKey:  message.ThreadKey,
+		MessageId:  messageID,
+		References: strings.Join(message.References, " "),
+		OutboxId:   outboxId,
+	}
+	if err := db_helper.Db().Create(&thread).Error; err != nil {
+		log_helper.Error(fmt.Sprintf("记录邮件会话失败: %v", err))
+	}
+}
+
+// ResolveThread 查找要回复的邮件，返回 In-Reply-To 和 References（均不含尖括号）
+// 指定 inReplyTo 时回复该邮件；否则回复 threadKey 下最近一封发送成功或在发送队列中等待投递的邮件，都没有时返回空
+func ResolveThread(threadKey string, inReplyTo string) (string, []string) {
+	inReplyTo = NormalizeMessageID(inReplyTo)
+	if inReplyTo == "" && threadKey == "" {
+		return "", nil
+	}
+
+	var parent model.EmailThread
+	var result *gorm.DB
+	if inReplyTo != "" {
+		result = db_helper.Db().Where("message_id = ?", inReplyTo).Order("id DESC").Limit(1).Find(&parent)
+		// 没有 thread_key 的邮件只在发送记录中
+		if result.Error == nil && result.RowsAffected == 0 {
+			result = db_helper.Db().Model(&model.EmailLog{}).Select("message_id", "references").
+				Where("message_id = ?", inReplyTo).Order("id DESC").Limit(1).Find(&parent)
+		}
+	} else {
+		// 投递失败或已取消的队列邮件不会到达收件人，跳过
+		threadTable := db_helper.Db().NamingStrategy.TableName("EmailThread")
+		outboxTable := db_helper.Db().NamingStrategy.TableName("EmailOutbox")
+		result = db_helper.Db().
+			Where("thread_key = ?", threadKey).
+			Where(threadTable+".outbox_id = 0 OR NOT EXISTS (SELECT 1 FROM "+outboxTable+" o WHERE o.id = "+threadTable+".outbox_id AND o.status IN ?)",
+				[]string{OutboxStatusFailed, OutboxStatusCanceled}).
+			Order("id DESC").Limit(1).Find(&parent)
+	}
+	if result.Error != nil {
+		log_helper.Error(fmt.Sprintf("查询邮件会话失败: %v", result.Error))
+	}
+	if result.Error != nil || result.RowsAffected == 0 {
+		// 回复的邮件不是本服务发送的，只引用该邮件
+		if inReplyTo != "" {
+			return inReplyTo, []string{inReplyTo}
+		}
+		return "", nil
+	}
+
+	references := append(strings.Fields(parent.References), parent.MessageId)
+	if len(references) > maxReferences {
+		references = append(references[:1], references[len(references)-maxReferences+1:]...)
+	}
+	return parent.MessageId, references
+}
@@ -0,0 +1,89 @@
+package email_helper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SMTP 连接加密方式
+const (
+	TLSModeImplicit      = "implicit"      // 直接建立 TLS 连接（SSL，通常为 465 端口）
+	TLSModeStartTLS      = "starttls"      // 明文连接后必须升级 STARTTLS，服务器不支持时发送失败
+	TLSModeOpportunistic = "opportunistic" // 服务器支持 STARTTLS 时升级，不支持时使用明文
+	TLSModeNone          = "none"          // 不加密
+)
+
+// TLS 最低版本配置值
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ResolveTLSMode 获取实际使用的加密方式，未配置时 465 端口使用 implicit，其他端口使用 opportunistic
+func ResolveTLSMode(config EmailConfig) (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(config.TLSMode))
+	switch mode {
+	case "":
+		if config.Port == 465 {
+			return TLSModeImplicit, nil
+		}
+		return TLSModeOpportunistic, nil
+	case TLSModeImplicit, "ssl", "tls":
+		return TLSModeImplicit, nil
+	case TLSModeStartTLS, TLSModeOpportunistic, TLSModeNone:
+		return mode, nil
+	}
+	return "", fmt.Errorf("不支持的 TLS 模式: %s，可选 implicit、starttls、opportunistic、none", config.TLSMode)
+}
+
+// buildTLSConfig 根据配置生成 tls.Config
+func buildTLSConfig(config EmailConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         config.Host,
+		InsecureSkipVerify: config.TLSSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if v := strings.TrimSpace(config.TLSMinVersion); v != "" {
+		version, ok := tlsVersions[v]
+		if !ok {
+			return nil, fmt.Errorf("不支持的 TLS 最低版本: %s，可选 1.0、1.1、1.2、1.3", v)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	// 自定义 CA 证书，在系统证书基础上追加
+	if config.TLSCAFile != "" {
+		pem, err := os.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 证书失败: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 证书格式错误: %s", config.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// 客户端证书（mTLS）
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		if config.TLSCertFile == "" || config.TLSKeyFile == "" {
+			return nil, fmt.Errorf("客户端证书和私钥需要同时配置")
+		}
+		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
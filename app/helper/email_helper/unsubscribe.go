@@ -0,0 +1,122 @@
+package email_helper
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gin_base/app/helper/db_helper"
+	"gin_base/app/model"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 退订方式
+const (
+	UnsubscribeMethodOneClick = "one-click" // RFC 8058 一键退订
+	UnsubscribeMethodPage     = "page"      // 退订确认页面
+)
+
+const (
+	// 启用退订但未指定分类时使用的分类
+	DefaultCategory = "default"
+	// 邮件分类的最大长度
+	maxCategoryLength = 50
+	// 退订令牌签名的长度（字节）
+	unsubscribeSignatureSize = 16
+)
+
+var errInvalidUnsubscribeToken = errors.New("退订链接无效")
+
+// ValidateCategory 校验邮件分类
+func ValidateCategory(category string) error {
+	if utf8.RuneCountInString(category) > maxCategoryLength {
+		return fmt.Errorf("邮件分类不能超过 %d 个字符", maxCategoryLength)
+	}
+	if strings.IndexFunc(category, unicode.IsControl) >= 0 {
+		return errors.New("邮件分类不能包含控制字符")
+	}
+	return nil
+}
+
+// NewUnsubscribeURL 生成收件人退订指定分类邮件的链接，链接中的令牌经过签名，无需保存
+// 服务地址读取环境变量 APP_URL，签名密钥读取 UNSUBSCRIBE_SECRET（未配置时使用 EMAIL_AUTH_CODE）
+func NewUnsubscribeURL(email string, category string) (string, error) {
+	appURL := strings.TrimRight(strings.TrimSpace(os.Getenv("APP_URL")), "/")
+	if appURL == "" {
+		return "", errors.New("未配置 APP_URL，无法生成退订链接")
+	}
+	secret := unsubscribeSecret()
+	if secret == nil {
+		return "", errors.New("未配置 UNSUBSCRIBE_SECRET，无法生成退订链接")
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(strings.ToLower(email) + "\n" + category))
+	token := payload + "." + base64.RawURLEncoding.EncodeToString(signUnsubscribe(secret, payload))
+	return appURL + "/unsubscribe?token=" + url.QueryEscape(token), nil
+}
+
+// ParseUnsubscribeToken 校验退订令牌，返回邮箱地址和分类
+func ParseUnsubscribeToken(token string) (string, string, error) {
+	secret := unsubscribeSecret()
+	payload, signature, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if secret == nil || !ok {
+		return "", "", errInvalidUnsubscribeToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(sig, signUnsubscribe(secret, payload)) {
+		return "", "", errInvalidUnsubscribeToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", "", errInvalidUnsubscribeToken
+	}
+	email, category, ok := strings.Cut(string(data), "\n")
+	if !ok || email == "" {
+		return "", "", errInvalidUnsubscribeToken
+	}
+	return email, category, nil
+}
+
+// RecordUnsubscribe 记录退订，写入该分类的抑制列表，来源为退订方式，同时记录请求IP和 User-Agent
+func RecordUnsubscribe(email string, category string, method string, requestIP string, userAgent string) error {
+	suppression, err := NewSuppression(email, category, SuppressionReasonUnsubscribe, method, "", nil)
+	if err != nil {
+		return err
+	}
+	suppression.RequestIP = truncateRunes(requestIP, maxSuppressionRequestIPLength)
+	suppression.UserAgent = truncateRunes(userAgent, maxSuppressionUserAgentLength)
+	return saveSuppressions([]model.EmailSuppression{suppression}, "reason", "source", "request_ip", "user_agent", "note", "expires_at", "updated_at")
+}
+
+// IsUnsubscribed 判断邮箱是否已退订该分类
+func IsUnsubscribed(email string, category string) bool {
+	var count int64
+	activeSuppressions(db_helper.Db().Model(&model.EmailSuppression{})).
+		Where("email = ? AND category = ? AND reason = ?", strings.ToLower(email), category, SuppressionReasonUnsubscribe).
+		Count(&count)
+	return count > 0
+}
+
+// unsubscribeSecret 退订令牌的签名密钥，未配置时返回 nil
+func unsubscribeSecret() []byte {
+	secret := os.Getenv("UNSUBSCRIBE_SECRET")
+	if secret == "" {
+		secret = os.Getenv("EMAIL_AUTH_CODE")
+	}
+	if secret == "" {
+		return nil
+	}
+	return []byte(secret)
+}
+
+// signUnsubscribe 计算令牌签名，截取 HMAC-SHA256 的前 16 字节以缩短链接
+func signUnsubscribe(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("unsubscribe:" + payload))
+	return mac.Sum(nil)[:unsubscribeSignatureSize]
+}
@@ -29,3 +29,18 @@ func CommonException(data ...any) {
 	}
 	panic(myException)
 }
+
+// 执行 fn 并捕获其中的通用异常，用于批量处理时单项出错不影响其他项，其他 panic 继续抛出
+func CatchException(fn func()) (exception *MyException) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(MyException)
+			if !ok {
+				panic(r)
+			}
+			exception = &e
+		}
+	}()
+	fn()
+	return nil
+}
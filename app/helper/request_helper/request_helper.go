@@ -6,6 +6,7 @@ import (
 	"gin_base/app/helper/valid_helper"
 	"github.com/gin-gonic/gin"
 	"github.com/goinggo/mapstructure"
+	"mime/multipart"
 	"net/url"
 	"reflect"
 	"strings"
@@ -48,6 +49,19 @@ func ParamRawJson(c *gin.Context, fields ...string) map[string]interface{} {
 	return paramAll(c, "ParamRawJson", fields...)
 }
 
+// 获取multi-form类型上传的文件，支持 field 和 field[] 两种写法
+func ParamMultipartFile(c *gin.Context, field string) []*multipart.FileHeader {
+	// 确保已解析过 multi-form 表单
+	ParamMultipartForm(c)
+	if c.Request.MultipartForm == nil || c.Request.MultipartForm.File == nil {
+		return nil
+	}
+	var files []*multipart.FileHeader
+	files = append(files, c.Request.MultipartForm.File[field]...)
+	files = append(files, c.Request.MultipartForm.File[field+"[]"]...)
+	return files
+}
+
 // 获取参数并验证
 func InputStruct(c *gin.Context, param interface{}) {
 	data := Input(c)
@@ -55,6 +69,12 @@ func InputStruct(c *gin.Context, param interface{}) {
 	valid_helper.Check(param)
 }
 
+// 将 map 参数解析到结构体并验证，用于批量请求中的每一项
+func MapStruct(data map[string]interface{}, param interface{}) {
+	mapstructure.Decode(data, param)
+	valid_helper.Check(param)
+}
+
 // 获取参数并验证
 func ParamGetStruct(c *gin.Context, param interface{}) {
 	data := ParamGet(c)
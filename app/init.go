@@ -35,10 +35,19 @@ func InitApp(initTypes ...string) {
 			case "mysql":
 				db.Set("gorm:table_options", "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_general_ci")
 			}
-			db.AutoMigrate(
+			err := db.AutoMigrate(
 				&model.User{},
 				&model.EmailLog{},
+				&model.EmailLogAttempt{},
+				&model.EmailLogRecipient{},
+				&model.EmailSuppression{},
+				&model.EmailOutbox{},
+				&model.EmailIdempotency{},
+				&model.EmailThread{},
 			)
+			if err != nil {
+				log_helper.Error("自动创建表失败: " + err.Error())
+			}
 		}
 	}
 
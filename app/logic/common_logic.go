@@ -1,5 +1,12 @@
 package logic
 
+import (
+	"fmt"
+	"gin_base/app/helper/exception_helper"
+	"strconv"
+	"strings"
+)
+
 // 限制字符长度，超过显示...超过显示
 func TruncateWithEllipsis(s string, maxLength int) string {
 	if maxLength < 3 {
@@ -13,3 +20,43 @@ func TruncateWithEllipsis(s string, maxLength int) string {
 
 	return string(runes[:maxLength-3]) + "..."
 }
+
+// ParseIds 解析 ID 列表参数，支持数组和逗号分隔的字符串
+func ParseIds(data interface{}, label string) []uint {
+	var items []string
+	switch v := data.(type) {
+	case nil:
+	case string:
+		items = strings.Split(v, ",")
+	case float64:
+		items = []string{formatId(v)}
+	case []interface{}:
+		for _, item := range v {
+			items = append(items, formatId(item))
+		}
+	default:
+		exception_helper.CommonException(label + "格式错误")
+	}
+
+	var ids []uint
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(item, 10, 64)
+		if err != nil || id == 0 {
+			exception_helper.CommonException(label + "格式错误")
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+// formatId 转换单个 ID 为字符串，JSON 数字解析为 float64，%v 会把 1000000 输出为 1e+06；带小数的值保留小数部分，后续解析时报错
+func formatId(item interface{}) string {
+	if v, ok := item.(float64); ok {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", item)
+}
@@ -0,0 +1,376 @@
+package logic
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gin_base/app/helper/email_helper"
+	"gin_base/app/helper/exception_helper"
+	"gin_base/app/helper/request_helper"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 附件总大小默认上限（MB）
+const defaultAttachmentMaxSize = 20
+
+// ParseAttachments 解析附件参数
+// 支持 multipart/form-data 上传的 attachments 文件，以及 JSON 中 [{filename, content, content_type}] 形式的 base64 附件
+func ParseAttachments(c *gin.Context, data interface{}) []email_helper.Attachment {
+	var attachments []email_helper.Attachment
+
+	// multipart 上传的文件
+	for _, fileHeader := range request_helper.ParamMultipartFile(c, "attachments") {
+		attachments = append(attachments, email_helper.NewAttachment(fileHeader.Filename, readUploadFile(fileHeader), ""))
+	}
+
+	// JSON 中的 base64 附件
+	for _, item := range parseObjectList(data, "附件") {
+		filename, content, contentType := parseBase64File(item, "附件")
+		attachments = append(attachments, email_helper.NewAttachment(filename, content, contentType))
+	}
+
+	return attachments
+}
+
+// ParseInlines 解析内联资源参数，HTML 正文中通过 cid:xxx 引用
+// 支持 multipart/form-data 上传的 inlines 文件（cid 为去掉扩展名的文件名），以及 JSON 中 [{cid, filename, content, content_type}] 形式的 base64 内容
+func ParseInlines(c *gin.Context, data interface{}) []email_helper.Attachment {
+	var inlines []email_helper.Attachment
+
+	// multipart 上传的文件
+	for _, fileHeader := range request_helper.ParamMultipartFile(c, "inlines") {
+		inlines = append(inlines, email_helper.NewInline("", fileHeader.Filename, readUploadFile(fileHeader), ""))
+	}
+
+	// JSON 中的 base64 内容
+	for _, item := range parseObjectList(data, "内联资源") {
+		filename, content, contentType := parseBase64File(item, "内联资源")
+		cid, _ := item["cid"].(string)
+		inlines = append(inlines, email_helper.NewInline(cid, filename, content, contentType))
+	}
+
+	return inlines
+}
+
+// CheckAttachmentSize 校验附件和内联资源总大小
+func CheckAttachmentSize(attachmentLists ...[]email_helper.Attachment) {
+	maxSize, _ := strconv.Atoi(os.Getenv("EMAIL_ATTACHMENT_MAX_SIZE"))
+	if maxSize <= 0 {
+		maxSize = defaultAttachmentMaxSize
+	}
+	total := 0
+	for _, attachments := range attachmentLists {
+		for _, attachment := range attachments {
+			total += len(attachment.Content)
+		}
+	}
+	if total > maxSize<<20 {
+		exception_helper.CommonException(fmt.Sprintf("附件总大小不能超过%dMB", maxSize))
+	}
+}
+
+// ReadUploadFileContent 读取 multipart 上传的单个文件内容，未上传时返回 nil
+func ReadUploadFileContent(c *gin.Context, field string) []byte {
+	files := request_helper.ParamMultipartFile(c, field)
+	if len(files) == 0 {
+		return nil
+	}
+	return readUploadFile(files[0])
+}
+
+// readUploadFile 读取上传文件内容
+func readUploadFile(fileHeader *multipart.FileHeader) []byte {
+	file, err := fileHeader.Open()
+	if err != nil {
+		exception_helper.CommonException("读取上传文件失败: " + fileHeader.Filename)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		exception_helper.CommonException("读取上传文件失败: " + fileHeader.Filename)
+	}
+	return content
+}
+
+// parseBase64File 解析 {filename, content, content_type} 形式的 base64 文件
+func parseBase64File(item map[string]interface{}, label string) (string, []byte, string) {
+	filename, _ := item["filename"].(string)
+	filename = strings.TrimSpace(filename)
+	if filename == "" {
+		exception_helper.CommonException(label + "文件名不能为空")
+	}
+	content, err := decodeBase64Content(item["content"])
+	if err != nil {
+		exception_helper.CommonException(label + "内容不是有效的base64: " + filename)
+	}
+	contentType, _ := item["content_type"].(string)
+	return filename, content, contentType
+}
+
+// parseObjectList 将参数解析为对象数组，兼容 JSON 数组和 JSON 字符串（表单提交）
+func parseObjectList(data interface{}, label string) []map[string]interface{} {
+	if data == nil {
+		return nil
+	}
+	if s, ok := data.(string); ok {
+		if strings.TrimSpace(s) == "" {
+			return nil
+		}
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			exception_helper.CommonException(label + "格式错误")
+		}
+		data = v
+	}
+
+	var list []interface{}
+	switch v := data.(type) {
+	case []interface{}:
+		list = v
+	case map[string]interface{}:
+		list = []interface{}{v}
+	default:
+		exception_helper.CommonException(label + "格式错误")
+	}
+
+	var result []map[string]interface{}
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			exception_helper.CommonException(label + "格式错误")
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// decodeBase64Content 解码 base64 内容，兼容 data:xxx;base64, 前缀和换行
+func decodeBase64Content(data interface{}) ([]byte, error) {
+	s, _ := data.(string)
+	if i := strings.Index(s, ";base64,"); i >= 0 && strings.HasPrefix(s, "data:") {
+		s = s[i+len(";base64,"):]
+	}
+	s = strings.NewReplacer("\r", "", "\n", "", " ", "").Replace(s)
+	if content, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return content, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// ParseBool 解析布尔参数，兼容字符串、数字、布尔
+func ParseBool(data interface{}) bool {
+	switch v := data.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "1" || v == "true"
+	case float64:
+		return v == 1
+	case int:
+		return v == 1
+	}
+	return false
+}
+
+// ParsePriority 解析优先级参数，支持 1/3/5 和 high/normal/low
+func ParsePriority(data interface{}) int {
+	value := strings.ToLower(strings.TrimSpace(fmt.Sprintf("%v", data)))
+	switch value {
+	case "", "<nil>", "0":
+		return email_helper.PriorityNone
+	case "1", "high", "urgent":
+		return email_helper.PriorityHigh
+	case "3", "normal":
+		return email_helper.PriorityNormal
+	case "5", "low":
+		return email_helper.PriorityLow
+	}
+	exception_helper.CommonException("优先级只能为 high/normal/low 或 1/3/5")
+	return email_helper.PriorityNone
+}
+
+// ParseHeaders 解析自定义信头参数，支持 JSON 对象、JSON 字符串和 headers[X-Name]=value 形式的表单参数
+func ParseHeaders(data interface{}) map[string]string {
+	headers := make(map[string]string)
+	switch v := data.(type) {
+	case nil:
+		return nil
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil
+		}
+		if err := json.Unmarshal([]byte(v), &headers); err != nil {
+			exception_helper.CommonException("自定义信头格式错误")
+		}
+	case map[string]string:
+		headers = v
+	case map[string]interface{}:
+		for name, value := range v {
+			headers[name] = fmt.Sprintf("%v", value)
+		}
+	default:
+		exception_helper.CommonException("自定义信头格式错误")
+	}
+	if err := email_helper.ValidateHeaders(headers); err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+	return headers
+}
+
+// ParseAddresses 解析收件人类参数
+// 支持逗号分隔的字符串（RFC 5322 地址列表，如 "Zhang, San" <z@x.com>）、字符串数组，以及 [{name, email}] 对象数组
+func ParseAddresses(data interface{}, label string) []mail.Address {
+	var addresses []mail.Address
+	appendList := func(s string) {
+		list, err := email_helper.ParseAddressList(s)
+		if err != nil {
+			exception_helper.CommonException(label + err.Error())
+		}
+		addresses = append(addresses, list...)
+	}
+
+	switch v := data.(type) {
+	case nil:
+	case string:
+		// 表单提交的 JSON 数组字符串
+		if trimmed := strings.TrimSpace(v); strings.HasPrefix(trimmed, "[") {
+			var list []interface{}
+			if err := json.Unmarshal([]byte(trimmed), &list); err != nil {
+				exception_helper.CommonException(label + "格式错误")
+			}
+			return ParseAddresses(list, label)
+		}
+		appendList(v)
+	case []interface{}:
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				appendList(item)
+			case map[string]interface{}:
+				name, _ := item["name"].(string)
+				email, _ := item["email"].(string)
+				if email == "" {
+					email, _ = item["address"].(string)
+				}
+				address, err := email_helper.NewAddress(name, email)
+				if err != nil {
+					exception_helper.CommonException(label + err.Error())
+				}
+				addresses = append(addresses, address)
+			default:
+				exception_helper.CommonException(label + "格式错误")
+			}
+		}
+	case map[string]string:
+		// 表单提交的 to[]=xxx 形式
+		for _, item := range v {
+			appendList(item)
+		}
+	default:
+		exception_helper.CommonException(label + "格式错误")
+	}
+	return addresses
+}
+
+// 日历邀请时间支持的格式，不带时区时按 timezone 参数解析
+var calendarTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// ParseCalendar 解析日历邀请参数，支持 JSON 对象和 JSON 字符串（表单提交）
+// {method, uid, sequence, summary, description, location, start, end, timezone, organizer, attendees}
+func ParseCalendar(data interface{}) *email_helper.CalendarEvent {
+	items := parseObjectList(data, "日历邀请")
+	if len(items) == 0 {
+		return nil
+	}
+	if len(items) > 1 {
+		exception_helper.CommonException("日历邀请只能包含一个事件")
+	}
+	item := items[0]
+
+	text := func(name string) string {
+		if item[name] == nil {
+			return ""
+		}
+		return strings.TrimSpace(fmt.Sprintf("%v", item[name]))
+	}
+	event := &email_helper.CalendarEvent{
+		Method:      text("method"),
+		UID:         text("uid"),
+		Summary:     text("summary"),
+		Description: text("description"),
+		Location:    text("location"),
+		Timezone:    text("timezone"),
+	}
+	if sequence := text("sequence"); sequence != "" {
+		value, err := strconv.Atoi(sequence)
+		if err != nil {
+			exception_helper.CommonException("日历邀请 sequence 必须为整数")
+		}
+		event.Sequence = value
+	}
+
+	loc, err := email_helper.CalendarLocation(event.Timezone)
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+	event.Start = parseCalendarTime(text("start"), loc, "开始时间")
+	event.End = parseCalendarTime(text("end"), loc, "结束时间")
+
+	if organizers := ParseAddresses(item["organizer"], "组织者"); len(organizers) > 1 {
+		exception_helper.CommonException("日历邀请只能有一个组织者")
+	} else if len(organizers) == 1 {
+		event.Organizer = organizers[0]
+	}
+	event.Attendees = ParseAddresses(item["attendees"], "参会人")
+
+	if err := email_helper.NormalizeCalendarEvent(event); err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+	return event
+}
+
+// parseCalendarTime 解析日历邀请时间，不带时区时使用 loc
+func parseCalendarTime(value string, loc *time.Location, label string) time.Time {
+	if value == "" {
+		exception_helper.CommonException("日历邀请" + label + "不能为空")
+	}
+	for _, layout := range calendarTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t
+		}
+	}
+	exception_helper.CommonException("日历邀请" + label + "格式错误，应为 2006-01-02 15:04:05 或 RFC3339 格式")
+	return time.Time{}
+}
+
+// ParseSendAt 解析预约发送时间，支持 RFC 3339 字符串和 Unix 时间戳，为空时返回零值（立即发送）
+func ParseSendAt(data interface{}) time.Time {
+	var value string
+	switch v := data.(type) {
+	case nil:
+	case string:
+		value = v
+	case float64:
+		value = strconv.FormatInt(int64(v), 10)
+	default:
+		exception_helper.CommonException("预约发送时间格式错误")
+	}
+	sendAt, err := email_helper.ParseSendAt(value, time.Now())
+	if err != nil {
+		exception_helper.CommonException(err.Error())
+	}
+	return sendAt
+}
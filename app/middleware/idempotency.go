@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gin_base/app/helper/email_helper"
+	"gin_base/app/helper/exception_helper"
+	"gin_base/app/helper/request_helper"
+	"gin_base/app/helper/response_helper"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 幂等中间件：请求带有 Idempotency-Key 信头或 idempotency_key 参数时，相同幂等键的请求只处理一次
+// 第一次处理完成的响应（成功或失败）在保存时间内直接返回（响应信头 Idempotent-Replayed: true），并发的重复请求等待第一次请求完成
+// 系统异常（500）和客户端已断开连接时不保存，相同幂等键可以重试；参数或上传文件不同的请求使用同一幂等键时返回错误
+func Idempotency() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		param := request_helper.Input(context)
+		key := strings.TrimSpace(context.GetHeader("Idempotency-Key"))
+		if key == "" {
+			if value, ok := param["idempotency_key"].(string); ok {
+				key = strings.TrimSpace(value)
+			}
+		}
+		if key == "" {
+			context.Next()
+			return
+		}
+		if err := email_helper.ValidateIdempotencyKey(key); err != nil {
+			exception_helper.CommonException(err.Error())
+		}
+		// 先验证授权码，未授权的请求不能占用幂等键，也不能让授权码错误的响应被保存
+		if authCode, _ := param["auth_code"].(string); authCode == "" || authCode != os.Getenv("EMAIL_AUTH_CODE") {
+			exception_helper.CommonException("授权码错误")
+		}
+
+		// 请求参数摘要，幂等键只能用于相同的请求
+		delete(param, "idempotency_key")
+		claim, err := email_helper.ClaimIdempotencyKey(key, requestFingerprint(context, param))
+		defer claim.Release()
+		if err != nil {
+			exception_helper.CommonException(err.Error(), http.StatusUnprocessableEntity)
+		}
+		if claim.Response != nil {
+			code := http.StatusOK
+			if value, ok := claim.Response["code"].(float64); ok {
+				code = int(value)
+			}
+			message, _ := claim.Response["message"].(string)
+			context.Header("Idempotent-Replayed", "true")
+			response_helper.Common(context, code, message, claim.Response["data"])
+			context.Abort()
+			return
+		}
+
+		// 参数错误、发送失败等业务异常与成功的响应一样保存，其他 panic 由 Exception 中间件处理
+		if exception := exception_helper.CatchException(context.Next); exception != nil {
+			response_helper.Common(context, exception.Code, exception.Message, exception.Data)
+			context.Abort()
+		}
+		if context.Request.Context().Err() != nil {
+			return
+		}
+		if data, ok := context.Get("response_data"); ok {
+			if response, ok := data.(map[string]interface{}); ok {
+				claim.Save(response)
+			}
+		}
+	}
+}
+
+// requestFingerprint 请求参数和上传文件内容的摘要
+func requestFingerprint(context *gin.Context, param map[string]interface{}) string {
+	hash := sha256.New()
+	data, _ := json.Marshal(param)
+	hash.Write(data)
+
+	if form := context.Request.MultipartForm; form != nil && len(form.File) > 0 {
+		fields := make([]string, 0, len(form.File))
+		for field := range form.File {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			for _, header := range form.File[field] {
+				fileHash := sha256.New()
+				if file, err := header.Open(); err == nil {
+					io.Copy(fileHash, file)
+					file.Close()
+				}
+				fmt.Fprintf(hash, "\n%s\n%s\n%x", field, header.Filename, fileHash.Sum(nil))
+			}
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
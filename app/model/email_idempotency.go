@@ -0,0 +1,17 @@
+package model
+
+import (
+	"gin_base/app/helper/type_helper"
+)
+
+// EmailIdempotency 发送接口的幂等键，未启用 Redis 时保存在数据库
+// 处理中的请求 Response 为空，LockedUntil 之前相同幂等键的请求等待其完成
+type EmailIdempotency struct {
+	Id             uint              `gorm:"primarykey;autoIncrement;comment:发送接口幂等键" json:"id"`
+	IdempotencyKey string            `gorm:"type:varchar(255);not null;default:'';uniqueIndex;comment:幂等键" json:"idempotency_key"`
+	Fingerprint    string            `gorm:"type:varchar(64);not null;default:'';comment:请求参数摘要" json:"fingerprint"`
+	Response       string            `gorm:"type:longtext;comment:响应JSON,为空时处理中" json:"response"`
+	LockedUntil    *type_helper.Time `gorm:"comment:处理超时时间" json:"locked_until"`
+	ExpiresAt      type_helper.Time  `gorm:"index;comment:过期时间" json:"expires_at"`
+	CreatedAt      type_helper.Time  `gorm:"comment:创建时间" json:"created_at"`
+}
@@ -7,16 +7,31 @@ import (
 // EmailLog 邮件发送记录
 type EmailLog struct {
 	Id          uint             `gorm:"primarykey;autoIncrement;comment:邮件发送记录表" json:"id"`
+	Account     string           `gorm:"type:varchar(50);not null;default:'default';index;comment:SMTP账号" json:"account"`
 	RequestIP   string           `gorm:"type:varchar(50);not null;default:'';comment:请求IP" json:"request_ip"`
 	ToEmail     string           `gorm:"type:text;comment:收件人(逗号分隔)" json:"to_email"`
 	CcEmail     string           `gorm:"type:text;comment:抄送(逗号分隔)" json:"cc_email"`
+	BccEmail    string           `gorm:"type:text;comment:密送(逗号分隔)" json:"bcc_email"`
+	ReplyTo     string           `gorm:"type:text;comment:回复地址(逗号分隔)" json:"reply_to"`
 	Subject     string           `gorm:"type:varchar(500);not null;default:'';comment:邮件主题" json:"subject"`
 	Body        string           `gorm:"type:longtext;comment:邮件正文" json:"body"`
 	IsHTML      int8             `gorm:"not null;default:0;comment:是否HTML格式,0-否,1-是" json:"is_html"`
+	Priority    int8             `gorm:"not null;default:0;comment:优先级,0-未设置,1-高,3-普通,5-低" json:"priority"`
+	Headers     string           `gorm:"type:text;comment:自定义信头JSON" json:"headers"`
+	OutboxId    uint             `gorm:"not null;default:0;index;comment:发送队列ID,0-同步发送" json:"outbox_id"`
+	ResendOf    uint             `gorm:"not null;default:0;index;comment:重发的原始发送记录ID,0-非重发" json:"resend_of"`
+	BatchId     string           `gorm:"type:varchar(32);not null;default:'';index;comment:个性化批量发送ID" json:"batch_id"`
+	Category    string           `gorm:"type:varchar(50);not null;default:'';index;comment:邮件分类" json:"category"`
+	ThreadKey   string           `gorm:"type:varchar(200);not null;default:'';index;comment:会话标识" json:"thread_key"`
+	MessageId   string           `gorm:"type:varchar(255);not null;default:'';index;comment:Message-ID" json:"message_id"`
+	InReplyTo   string           `gorm:"type:varchar(255);not null;default:'';comment:回复的Message-ID" json:"in_reply_to"`
+	References  string           `gorm:"type:text;comment:References(空格分隔的Message-ID)" json:"references"`
 	Success     int8             `gorm:"not null;default:0;comment:是否成功,0-失败,1-成功" json:"success"`
 	Error       string           `gorm:"type:text;comment:错误信息" json:"error"`
+	ErrorType   string           `gorm:"type:varchar(20);not null;default:'';comment:错误类型,timeout-超时,canceled-已取消,connection-连接错误,temporary-临时错误,permanent-永久错误" json:"error_type"`
 	SmtpHost    string           `gorm:"type:varchar(200);not null;default:'';comment:SMTP服务器" json:"smtp_host"`
 	SmtpPort    int              `gorm:"not null;default:0;comment:SMTP端口" json:"smtp_port"`
+	Attachments string           `gorm:"type:text;comment:附件信息JSON" json:"attachments"`
 	RequestData string           `gorm:"type:longtext;comment:请求参数JSON" json:"request_data"`
 	CreatedAt   type_helper.Time `gorm:"comment:创建时间" json:"created_at"`
 }
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"gin_base/app/helper/type_helper"
+)
+
+// EmailLogAttempt 邮件投递尝试记录，故障转移时一条发送记录对应多次尝试
+type EmailLogAttempt struct {
+	Id         uint             `gorm:"primarykey;autoIncrement;comment:邮件投递尝试记录表" json:"id"`
+	EmailLogId uint             `gorm:"not null;default:0;index;comment:邮件发送记录ID" json:"email_log_id"`
+	Sort       int              `gorm:"not null;default:0;comment:尝试顺序" json:"sort"`
+	Account    string           `gorm:"type:varchar(50);not null;default:'';comment:SMTP账号" json:"account"`
+	SmtpHost   string           `gorm:"type:varchar(200);not null;default:'';comment:SMTP服务器" json:"smtp_host"`
+	SmtpPort   int              `gorm:"not null;default:0;comment:SMTP端口" json:"smtp_port"`
+	Success    int8             `gorm:"not null;default:0;comment:是否成功,0-失败,1-成功" json:"success"`
+	Error      string           `gorm:"type:text;comment:错误信息" json:"error"`
+	ErrorType  string           `gorm:"type:varchar(20);not null;default:'';comment:错误类型" json:"error_type"`
+	Code       int              `gorm:"not null;default:0;comment:SMTP响应码" json:"code"`
+	LatencyMs  int64            `gorm:"not null;default:0;comment:耗时(毫秒)" json:"latency_ms"`
+	CreatedAt  type_helper.Time `gorm:"comment:创建时间" json:"created_at"`
+}
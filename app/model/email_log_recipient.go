@@ -0,0 +1,17 @@
+package model
+
+import (
+	"gin_base/app/helper/type_helper"
+)
+
+// EmailLogRecipient 邮件收件人投递结果
+type EmailLogRecipient struct {
+	Id         uint             `gorm:"primarykey;autoIncrement;comment:邮件收件人投递结果表" json:"id"`
+	EmailLogId uint             `gorm:"not null;default:0;index;comment:邮件发送记录ID" json:"email_log_id"`
+	Email      string           `gorm:"type:varchar(320);not null;default:'';comment:邮箱地址" json:"email"`
+	Type       string           `gorm:"type:varchar(10);not null;default:'';comment:收件人类型,to-收件人,cc-抄送,bcc-密送" json:"type"`
+	Status     string           `gorm:"type:varchar(20);not null;default:'';comment:投递状态,sent-已接收,rejected-被拒绝,failed-失败,skipped-在抑制列表中未投递" json:"status"`
+	Code       int              `gorm:"not null;default:0;comment:SMTP响应码" json:"code"`
+	Message    string           `gorm:"type:text;comment:SMTP响应内容" json:"message"`
+	CreatedAt  type_helper.Time `gorm:"comment:创建时间" json:"created_at"`
+}
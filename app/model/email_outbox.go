@@ -0,0 +1,34 @@
+package model
+
+import (
+	"gin_base/app/helper/type_helper"
+)
+
+// EmailOutbox 异步发送队列，邮件入队后由工作协程投递，临时错误时按指数退避重试
+// 预约发送的邮件状态为 scheduled，到达预约时间后由定时任务转为 pending
+type EmailOutbox struct {
+	Id            uint              `gorm:"primarykey;autoIncrement;comment:邮件发送队列" json:"id"`
+	Status        string            `gorm:"type:varchar(20);not null;default:'';index:idx_status_next_attempt;comment:状态,scheduled-已预约,pending-待投递,sending-投递中,sent-已发送,failed-失败,canceled-已取消" json:"status"`
+	Account       string            `gorm:"type:varchar(50);not null;default:'default';comment:SMTP账号" json:"account"`
+	FromName      string            `gorm:"type:varchar(200);not null;default:'';comment:发件人名称,为空时使用账号配置" json:"from_name"`
+	RequestIP     string            `gorm:"type:varchar(50);not null;default:'';comment:请求IP" json:"request_ip"`
+	ToEmail       string            `gorm:"type:text;comment:收件人(逗号分隔)" json:"to_email"`
+	Subject       string            `gorm:"type:varchar(500);not null;default:'';comment:邮件主题" json:"subject"`
+	MessageId     string            `gorm:"type:varchar(255);not null;default:'';index;comment:Message-ID" json:"message_id"`
+	Message       string            `gorm:"type:longtext;comment:邮件内容JSON(含附件)" json:"-"`
+	RequestData   string            `gorm:"type:longtext;comment:请求参数JSON" json:"-"`
+	Attempts      int               `gorm:"not null;default:0;comment:已投递次数" json:"attempts"`
+	MaxAttempts   int               `gorm:"not null;default:0;comment:最大投递次数" json:"max_attempts"`
+	SendAt        *type_helper.Time `gorm:"index;comment:预约发送时间,为空时立即发送" json:"send_at"`
+	NextAttemptAt type_helper.Time  `gorm:"index:idx_status_next_attempt;comment:下次投递时间" json:"next_attempt_at"`
+	LockedAt      *type_helper.Time `gorm:"comment:开始投递时间" json:"locked_at"`
+	LastError     string            `gorm:"type:text;comment:最近一次错误" json:"last_error"`
+	ErrorType     string            `gorm:"type:varchar(20);not null;default:'';comment:最近一次错误类型" json:"error_type"`
+	EmailLogId    uint              `gorm:"not null;default:0;comment:最近一次投递的发送记录ID" json:"email_log_id"`
+	ResendOf      uint              `gorm:"not null;default:0;comment:重发的原始发送记录ID,0-非重发" json:"resend_of"`
+	BatchId       string            `gorm:"type:varchar(32);not null;default:'';comment:个性化批量发送ID" json:"batch_id"`
+	SentAt        *type_helper.Time `gorm:"comment:发送成功时间" json:"sent_at"`
+	CanceledAt    *type_helper.Time `gorm:"comment:取消时间" json:"canceled_at"`
+	CreatedAt     type_helper.Time  `gorm:"comment:入队时间" json:"created_at"`
+	UpdatedAt     type_helper.Time  `gorm:"comment:更新时间" json:"updated_at"`
+}
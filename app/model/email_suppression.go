@@ -0,0 +1,21 @@
+package model
+
+import (
+	"gin_base/app/helper/type_helper"
+)
+
+// EmailSuppression 邮件抑制列表，列表中的地址不再投递
+// Category 为空时对所有邮件生效（全局），否则只对该分类的邮件生效
+type EmailSuppression struct {
+	Id        uint              `gorm:"primarykey;autoIncrement;comment:邮件抑制列表" json:"id"`
+	Email     string            `gorm:"type:varchar(255);not null;default:'';uniqueIndex:idx_email_suppression_email_category;comment:邮箱地址(小写)" json:"email"`
+	Category  string            `gorm:"type:varchar(50);not null;default:'';uniqueIndex:idx_email_suppression_email_category;comment:作用范围,空-全局,其他-邮件分类" json:"category"`
+	Reason    string            `gorm:"type:varchar(20);not null;default:'';index;comment:原因,bounce-退信,complaint-投诉,unsubscribe-退订,manual-手动" json:"reason"`
+	Source    string            `gorm:"type:varchar(100);not null;default:'';comment:来源,如api,import,dashboard,one-click,page" json:"source"`
+	RequestIP string            `gorm:"type:varchar(50);not null;default:'';comment:退订时的请求IP" json:"request_ip"`
+	UserAgent string            `gorm:"type:varchar(500);not null;default:'';comment:退订时的User-Agent" json:"user_agent"`
+	Note      string            `gorm:"type:varchar(500);not null;default:'';comment:备注" json:"note"`
+	ExpiresAt *type_helper.Time `gorm:"index;comment:过期时间,为空时永久有效" json:"expires_at"`
+	CreatedAt type_helper.Time  `gorm:"comment:创建时间" json:"created_at"`
+	UpdatedAt type_helper.Time  `gorm:"comment:更新时间" json:"updated_at"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"gin_base/app/helper/type_helper"
+)
+
+// EmailThread 会话中的邮件，同步发送成功或入队时立即写入，同一会话的下一封邮件据此设置 In-Reply-To 和 References
+type EmailThread struct {
+	Id         uint             `gorm:"primarykey;autoIncrement;comment:邮件会话表" json:"id"`
+	ThreadKey  string           `gorm:"type:varchar(200);not null;default:'';index;comment:会话标识" json:"thread_key"`
+	MessageId  string           `gorm:"type:varchar(255);not null;default:'';index;comment:Message-ID" json:"message_id"`
+	References string           `gorm:"type:text;comment:References(空格分隔的Message-ID)" json:"references"`
+	OutboxId   uint             `gorm:"not null;default:0;comment:发送队列ID,0-同步发送" json:"outbox_id"`
+	CreatedAt  type_helper.Time `gorm:"comment:创建时间" json:"created_at"`
+}
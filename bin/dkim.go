@@ -0,0 +1,81 @@
+package bin
+
+import (
+	"fmt"
+	"gin_base/app/helper/email_helper"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func DkimCommand() *cobra.Command {
+	var domain, selector, algorithm, outDir string
+	var bits int
+
+	cmd := &cobra.Command{
+		Use:   "dkim",
+		Short: "生成DKIM密钥对",
+		Long:  "生成DKIM密钥对，私钥保存到文件，并输出需要发布的DNS TXT记录",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := generateDkimKey(domain, selector, algorithm, bits, outDir); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&domain, "domain", "", "发件域名，如 example.com")
+	cmd.Flags().StringVar(&selector, "selector", "mail", "DKIM selector")
+	cmd.Flags().StringVar(&algorithm, "algorithm", email_helper.DKIMAlgorithmRSA, "签名算法：rsa、ed25519")
+	cmd.Flags().IntVar(&bits, "bits", 2048, "RSA 密钥长度")
+	cmd.Flags().StringVar(&outDir, "out", "runtime/dkim", "私钥保存目录")
+	cmd.MarkFlagRequired("domain")
+
+	return cmd
+}
+
+// 生成密钥对并输出DNS记录
+func generateDkimKey(domain, selector, algorithm string, bits int, outDir string) error {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	selector = strings.TrimSpace(selector)
+	if domain == "" || selector == "" {
+		return fmt.Errorf("domain 和 selector 不能为空")
+	}
+
+	privatePEM, record, err := email_helper.GenerateDKIMKey(algorithm, bits)
+	if err != nil {
+		return err
+	}
+
+	keyFile := filepath.Join(outDir, domain+"."+selector+".pem")
+	if _, err := os.Stat(keyFile); err == nil {
+		return fmt.Errorf("私钥文件已存在: %s", keyFile)
+	}
+	if err := os.MkdirAll(outDir, 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, privatePEM, 0600); err != nil {
+		return err
+	}
+
+	fmt.Printf("私钥已保存: %s\n\n", keyFile)
+	fmt.Println("请在DNS中添加TXT记录：")
+	fmt.Printf("  名称: %s\n", email_helper.DKIMRecordName(selector, domain))
+	fmt.Printf("  内容: %s\n\n", record)
+	// 单个 TXT 字符串最长 255 字节，RSA 公钥需要拆分为多个字符串
+	if len(record) > 255 {
+		var parts []string
+		for i := 0; i < len(record); i += 255 {
+			end := i + 255
+			if end > len(record) {
+				end = len(record)
+			}
+			parts = append(parts, `"`+record[i:end]+`"`)
+		}
+		fmt.Println("部分DNS服务商需要拆分为多个字符串：")
+		fmt.Printf("  %s\n\n", strings.Join(parts, " "))
+	}
+	fmt.Println("并在 app/appconfig/dkim.yaml 中添加：")
+	fmt.Printf("  domains:\n    - domain: %s\n      selector: %s\n      private_key_file: %s\n", domain, selector, keyFile)
+	return nil
+}
@@ -1,6 +1,7 @@
 package bin
 
 import (
+	"gin_base/app/helper/email_helper"
 	"gin_base/app/middleware"
 	"gin_base/route"
 	"github.com/gin-gonic/gin"
@@ -36,6 +37,8 @@ func StartServer() {
 	middleware.InitMiddleware(engine)
 	//初始化路由
 	route.InitRouter(engine)
+	//启动异步发送队列
+	email_helper.StartOutboxWorkers()
     //自定义端口
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -32,6 +32,7 @@ func main() {
 	cmd.AddCommand(bin.ServeCommand())   //启动Gin服务命令
 	cmd.AddCommand(bin.DebugCommand())   //调试专用
 	cmd.AddCommand(bin.MigrateCommand()) //数据库迁移
+	cmd.AddCommand(bin.DkimCommand())    //生成DKIM密钥
 
 	///////////////////
 	//自定义命令结束
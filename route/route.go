@@ -19,13 +19,35 @@ func InitRouter(e *gin.Engine) {
 	// favicon
 	e.StaticFile("/favicon.png", "./static/image/favicon.png")
 
+	// 退订：确认页面和 RFC 8058 一键退订
+	e.GET("/unsubscribe", common.UnsubscribeIndex)
+	e.POST("/unsubscribe", common.Unsubscribe)
+
 	api := e.Group("/api")
 	api.GET("/test", common.Test)
-	api.Any("/email", common.Email)
+	api.Any("/email", middleware.Idempotency(), common.Email)
+	api.POST("/email/batch", middleware.Idempotency(), common.EmailBatch)
+	api.POST("/email/merge", middleware.Idempotency(), common.EmailMerge)
 
 	// 邮件记录API
 	api.POST("/getEmailLogList", common.GetEmailLogList)
 	api.POST("/deleteEmailLog", common.DeleteEmailLog)
+	api.POST("/resendEmailLog", common.ResendEmailLog)
+
+	// 抑制列表API
+	api.POST("/getSuppressionList", common.GetSuppressionList)
+	api.POST("/addSuppression", common.AddSuppression)
+	api.POST("/importSuppression", common.ImportSuppression)
+	api.POST("/deleteSuppression", common.DeleteSuppression)
+
+	// 异步发送状态和预约发送
+	api.POST("/getEmailStatus", common.GetEmailStatus)
+	api.POST("/getScheduledEmailList", common.GetScheduledEmailList)
+	api.POST("/rescheduleEmail", common.RescheduleEmail)
+	api.POST("/cancelEmail", common.CancelEmail)
+
+	// SMTP 连接池统计
+	api.POST("/getEmailPoolStats", common.GetEmailPoolStats)
 
 	//登录相关
 	auth := api.Group("", middleware.Auth())